@@ -0,0 +1,560 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"itextmine/config"
+	"itextmine/convert"
+	"itextmine/misc"
+	"itextmine/tools"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// GlobalOptions apply to every command
+type GlobalOptions struct {
+	ConfigFile string `long:"config" description:"Full path to a yaml config file with defaults of the flags and settings of the tools. The flags given on the command line override it"`
+}
+
+// ToolOptions select the tool and its workdir, every command that works on a workdir has them
+type ToolOptions struct {
+	Tool        string `short:"t" long:"toolname" description:"Name of the text mining tool to run. Options are the registered tools, e.g. rlimsp, mirtex" required:"true"`
+	Workdir     string `short:"w" long:"workdir" description:"Full path to the workdir. Please ensure that the user has rw access to the directory" required:"true"`
+	ManifestDir string `short:"m" long:"manifestdir" description:"Full path to a directory of yaml/json tool manifests to register next to the built in tools"`
+}
+
+// SplitOptions control how the input docs are split into tasks
+type SplitOptions struct {
+	InputDocs    []string    `short:"i" long:"inputfile" description:"Full path to an input file, optionally compressed as .gz or .zst, a directory of input files, a glob pattern or - for stdin. Repeat to split several inputs into one set of tasks. Please ensure that the user has read access to the files" required:"true"`
+	LinesPerTask int         `short:"l" long:"linespertask" description:"Number of lines per tasks" default:"100"`
+	CharsPerTask int         `long:"charspertask" description:"Number of text characters per task, to balance the tasks by document length. A task is closed at whichever of the two limits comes first, use -l 0 to split by text only"`
+	GroupBy      string      `long:"groupby" description:"Keep the records sharing the value of this field in the same task, e.g. pmcid to keep the sections of a PMC article together"`
+	Validate     config.Bool `long:"validate" description:"Check the documents against the medline or pmc schema of the collection and write the rejected ones to <workdir>/<tool>.rejected.jsonl" optional:"yes" optional-value:"true"`
+	MaxRejected  float64     `long:"maxrejected" description:"Abort when a larger fraction of the documents is rejected by --validate, e.g. 0.01" default:"0"`
+}
+
+// RuntimeOptions select the backend that runs the tool containers
+type RuntimeOptions struct {
+	Backend       string `short:"b" long:"backend" description:"Backend that runs the tools. Options are docker, podman, local" default:"docker" choice:"docker" choice:"podman" choice:"local"`
+	PodmanSocket  string `long:"podmansocket" description:"Full path to the podman api socket. Defaults to the rootless socket of the user"`
+	LocalCommands string `long:"localcommands" description:"Full path to the yaml file mapping the tool images to local commands. Required by the local backend"`
+	RunID         string `long:"runid" description:"Prefix of the container and network names of the run, and the label that clean removes them by. Defaults to an id of the workdir, so that runs on different workdirs do not collide. A run id given here also moves the network of the tool to a subnet of the run"`
+}
+
+// ExecuteOptions control how the tasks are executed
+type ExecuteOptions struct {
+	NumberOfTask  int                      `short:"n" long:"numtasks" description:"Number of parallel tasks" default:"10"`
+	Resume        config.Bool              `short:"r" long:"resume" description:"Keep the task folders of a previous run and only execute the stages that did not complete" optional:"yes" optional-value:"true"`
+	Retries       int                      `long:"retries" description:"Number of retries of a stage failing with a transient docker error" default:"2"`
+	StageRetries  map[string]int           `long:"stageretries" description:"Number of retries of a stage, overriding --retries. The align key applies to all align stages, e.g. --stageretries efip:4"`
+	RetryBackoff  time.Duration            `long:"retrybackoff" description:"Wait before the first retry, doubled for every further retry" default:"5s"`
+	MaxBackoff    time.Duration            `long:"maxretrybackoff" description:"Longest wait between two retries" default:"1m"`
+	StageTimeout  time.Duration            `long:"stagetimeout" description:"Stop a stage of a task running longer than this, e.g. 30m. No timeout when 0" default:"0"`
+	StageTimeouts map[string]time.Duration `long:"stagetimeouts" description:"Timeout of a stage, overriding --stagetimeout. The align key applies to all align stages, e.g. --stagetimeouts rlimsp:2h"`
+	RunTimeout    time.Duration            `long:"runtimeout" description:"Stop the run once it takes longer than this, e.g. 12h. No deadline when 0" default:"0"`
+	Aligner       string                   `long:"aligner" description:"Aligner of the tool outputs, overrides the tool manifests. Options are container, native" choice:"container" choice:"native"`
+}
+
+// ReduceOptions control how the task outputs are reduced
+type ReduceOptions struct {
+	OutputDir      string      `short:"o" long:"outputdir" description:"Full path to the output directory. Please ensure that the user has rw access to the directory" required:"true"`
+	CollectionType string      `short:"c" long:"collection" description:"Type of collection" required:"true"`
+	SortByDocId    config.Bool `long:"sortbydocid" description:"Order the reduced records by docId instead of the input doc order" optional:"yes" optional-value:"true"`
+	Compression    string      `long:"compress" description:"Compress the reduced outputs. Options are gz, zst" choice:"gz" choice:"zst"`
+}
+
+// PipelineCommand splits the input docs, runs the tool and reduces the outputs in one go
+type PipelineCommand struct {
+	ToolOptions    `group:"Tool Options"`
+	SplitOptions   `group:"Split Options"`
+	RuntimeOptions `group:"Runtime Options"`
+	ExecuteOptions `group:"Execute Options"`
+	ReduceOptions  `group:"Reduce Options"`
+
+	DryRun bool `long:"dry-run" description:"Show the tasks, images, containers and outputs of the run without touching the container runtime or the workdir"`
+}
+
+// SplitCommand splits the input docs into the task folders of the tool
+type SplitCommand struct {
+	ToolOptions  `group:"Tool Options"`
+	SplitOptions `group:"Split Options"`
+
+	CollectionType string `short:"c" long:"collection" description:"Type of collection, checked by --validate"`
+}
+
+// RunCommand runs the tool on task folders that were split before
+type RunCommand struct {
+	ToolOptions    `group:"Tool Options"`
+	RuntimeOptions `group:"Runtime Options"`
+	ExecuteOptions `group:"Execute Options"`
+
+	OutputDir string `short:"o" long:"outputdir" description:"Full path to the directory of the failure report. Defaults to the workdir"`
+}
+
+// AlignCommand runs the align stages again, e.g. after a tool output was fixed
+type AlignCommand struct {
+	ToolOptions    `group:"Tool Options"`
+	RuntimeOptions `group:"Runtime Options"`
+	ExecuteOptions `group:"Execute Options"`
+
+	OutputDir string `short:"o" long:"outputdir" description:"Full path to the directory of the failure report. Defaults to the workdir"`
+}
+
+// ReduceCommand reduces the task outputs of the workdir
+type ReduceCommand struct {
+	ToolOptions   `group:"Tool Options"`
+	ReduceOptions `group:"Reduce Options"`
+}
+
+// CleanCommand removes what a run left behind
+type CleanCommand struct {
+	ToolOptions    `group:"Tool Options"`
+	RuntimeOptions `group:"Runtime Options"`
+
+	All bool `long:"all" description:"Also remove the task folders, the checkpoint and the rejected docs of the tool"`
+}
+
+// StatusCommand shows the progress of a run from its checkpoint
+type StatusCommand struct {
+	ToolOptions `group:"Tool Options"`
+
+	Json bool `long:"json" description:"Print the status as json"`
+}
+
+// ConvertCommand converts source files to input docs, e.g. convert medline -i pubmed20n0001.xml.gz -o medline.json
+type ConvertCommand struct {
+	InputFiles []string `short:"i" long:"inputfile" description:"Full path to a source file, optionally compressed as .gz or .zst, a directory of source files, a glob pattern or - for stdin. Can be repeated" required:"true"`
+	OutputFile string   `short:"o" long:"outputfile" description:"Full path to the json lines output, compressed when it ends with .gz or .zst. - for stdout" default:"-"`
+	Args       struct {
+		Format string `positional-arg-name:"format" description:"Format of the source files. Options are medline, pmc"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// ConfigCommand groups the commands on the configuration
+type ConfigCommand struct {
+	Show ConfigShowCommand `command:"show" description:"Print the effective configuration, the config file with the flags given on the command line applied"`
+}
+
+// ConfigShowCommand prints the effective configuration as a config file, e.g. config show --config pipeline.yaml -n 20
+type ConfigShowCommand struct {
+	ToolOptions    `group:"Tool Options"`
+	SplitOptions   `group:"Split Options"`
+	RuntimeOptions `group:"Runtime Options"`
+	ExecuteOptions `group:"Execute Options"`
+	ReduceOptions  `group:"Reduce Options"`
+}
+
+func (command *PipelineCommand) Execute(args []string) error {
+	registerError := command.registerManifests()
+	if registerError != nil {
+		return registerError
+	}
+
+	validateError := validateArguments(*command)
+	if validateError != nil {
+		return validateError
+	}
+
+	if command.DryRun {
+		return command.dryRun()
+	}
+
+	// split the input doc, unless we resume a run that was already split
+	if bool(command.Resume) && misc.TaskFoldersExist(command.Workdir, command.Tool) {
+		log.Println(fmt.Sprintf("Resuming with the task folders in %s", path.Join(command.Workdir, command.Tool)))
+	} else {
+		splitError := split(command.ToolOptions, command.SplitOptions, command.CollectionType)
+		if splitError != nil {
+			return splitError
+		}
+	}
+
+	runError := run(command.ToolOptions, command.RuntimeOptions, command.executeOptions(command.Workdir), command.OutputDir)
+	if runError != nil {
+		return runError
+	}
+
+	return reduce(command.ToolOptions, command.ReduceOptions)
+}
+
+// dryRun shows what the pipeline would do, without touching the container runtime or the workdir
+func (command *PipelineCommand) dryRun() error {
+	taskCount := 0
+	if bool(command.Resume) && misc.TaskFoldersExist(command.Workdir, command.Tool) {
+		// the task folders of the run that is resumed
+		taskNames, taskNamesError := misc.GetSubDirNames(path.Join(command.Workdir, command.Tool))
+		if taskNamesError != nil {
+			return taskNamesError
+		}
+		taskCount = len(*taskNames)
+		fmt.Println(fmt.Sprintf("Resuming with the %d task folders in %s", taskCount, path.Join(command.Workdir, command.Tool)))
+	} else {
+		inputDocPaths, inputDocsError := misc.ResolveInputDocs(command.InputDocs)
+		if inputDocsError != nil {
+			return inputDocsError
+		}
+
+		// split the input docs without writing the task folders
+		splitOptions := command.splitOptions(command.CollectionType)
+		counts, planError := misc.PlanSplit(inputDocPaths, splitOptions)
+		if planError != nil {
+			return planError
+		}
+		taskCount = counts.Tasks
+
+		fmt.Println(fmt.Sprintf("Input files: %d", len(inputDocPaths)))
+		fmt.Println(fmt.Sprintf("Documents: %d", counts.Documents))
+		if command.Validate {
+			fmt.Println(fmt.Sprintf("Rejected documents: %d", counts.Rejected))
+			if splitOptions.TooManyRejected(counts) {
+				return errors.New(fmt.Sprintf("%d of %d documents would be rejected, more than the allowed ratio %g", counts.Rejected, counts.Documents, command.MaxRejected))
+			}
+		}
+		fmt.Println(fmt.Sprintf("Tasks: %d in %s", taskCount, path.Join(command.Workdir, command.Tool)))
+	}
+
+	plan, planError := tools.PlanRun(command.Tool, command.Aligner, command.runID(command.Workdir), taskCount)
+	if planError != nil {
+		return planError
+	}
+	fmt.Println(plan.String())
+
+	// the reduced files
+	tool, toolError := tools.GetTool(command.Tool)
+	if toolError != nil {
+		return toolError
+	}
+
+	fmt.Println("Outputs:")
+	for _, reduceOutput := range tool.ReduceOutputs() {
+		fmt.Println(fmt.Sprintf("  %s", tools.ReduceOutputPath(command.OutputDir, command.CollectionType, reduceOutput, tools.ReduceOptions{Compression: command.Compression})))
+	}
+
+	return nil
+}
+
+func (command *SplitCommand) Execute(args []string) error {
+	registerError := command.registerManifests()
+	if registerError != nil {
+		return registerError
+	}
+
+	splitError := split(command.ToolOptions, command.SplitOptions, command.CollectionType)
+	if splitError != nil {
+		return splitError
+	}
+
+	// the stages recorded for the previous tasks do not apply to the new ones
+	checkpointRemoveError := os.Remove(tools.CheckpointPath(command.Workdir, command.Tool))
+	if checkpointRemoveError != nil && os.IsNotExist(checkpointRemoveError) == false {
+		return checkpointRemoveError
+	}
+
+	return nil
+}
+
+func (command *RunCommand) Execute(args []string) error {
+	registerError := command.registerManifests()
+	if registerError != nil {
+		return registerError
+	}
+
+	splitError := command.checkSplit()
+	if splitError != nil {
+		return splitError
+	}
+
+	return run(command.ToolOptions, command.RuntimeOptions, command.executeOptions(command.Workdir), command.reportDir(command.OutputDir))
+}
+
+func (command *AlignCommand) Execute(args []string) error {
+	registerError := command.registerManifests()
+	if registerError != nil {
+		return registerError
+	}
+
+	splitError := command.checkSplit()
+	if splitError != nil {
+		return splitError
+	}
+
+	tool, toolError := tools.GetTool(command.Tool)
+	if toolError != nil {
+		return toolError
+	}
+
+	// only the align stages are executed, on the tasks whose tool stage is done
+	executeOptions := command.executeOptions(command.Workdir)
+	for _, stage := range tool.Stages() {
+		if strings.HasSuffix(stage, tools.ALIGN_STAGE_SUFFIX) {
+			executeOptions.Stages = append(executeOptions.Stages, stage)
+		}
+	}
+	if len(executeOptions.Stages) == 0 {
+		return errors.New(fmt.Sprintf("Tool %s has no align stages", tool.Name()))
+	}
+
+	return run(command.ToolOptions, command.RuntimeOptions, executeOptions, command.reportDir(command.OutputDir))
+}
+
+func (command *ReduceCommand) Execute(args []string) error {
+	registerError := command.registerManifests()
+	if registerError != nil {
+		return registerError
+	}
+
+	return reduce(command.ToolOptions, command.ReduceOptions)
+}
+
+func (command *CleanCommand) Execute(args []string) error {
+	registerError := command.registerManifests()
+	if registerError != nil {
+		return registerError
+	}
+
+	containerRuntime, runtimeError := createContainerRuntime(command.RuntimeOptions)
+	if runtimeError != nil {
+		return runtimeError
+	}
+
+	runID := command.runID(command.Workdir)
+	log.Println(fmt.Sprintf("Removing the containers and networks of %s run %s", command.Tool, runID))
+	cleanError := tools.Clean(containerRuntime, command.Tool, runID)
+	if cleanError != nil {
+		return cleanError
+	}
+
+	if command.All == false {
+		return nil
+	}
+
+	// the files of the tool in the workdir
+	for _, filePath := range []string{
+		path.Join(command.Workdir, command.Tool),
+		tools.CheckpointPath(command.Workdir, command.Tool),
+		misc.RejectedPath(command.Workdir, command.Tool),
+	} {
+		log.Println(fmt.Sprintf("Removing %s", filePath))
+		removeError := os.RemoveAll(filePath)
+		if removeError != nil {
+			return removeError
+		}
+	}
+
+	return nil
+}
+
+func (command *StatusCommand) Execute(args []string) error {
+	registerError := command.registerManifests()
+	if registerError != nil {
+		return registerError
+	}
+
+	status, statusError := tools.Status(command.Workdir, command.Tool)
+	if statusError != nil {
+		return statusError
+	}
+
+	if command.Json {
+		statusBytes, marshalError := json.MarshalIndent(status, "", "  ")
+		if marshalError != nil {
+			return marshalError
+		}
+		fmt.Println(string(statusBytes))
+		return nil
+	}
+
+	fmt.Println(status.String())
+	return nil
+}
+
+func (command *ConvertCommand) Execute(args []string) error {
+	converter, converterError := convert.GetConverter(command.Args.Format)
+	if converterError != nil {
+		return converterError
+	}
+
+	inputPaths, inputPathsError := misc.ResolveInputDocs(command.InputFiles)
+	if inputPathsError != nil {
+		return inputPathsError
+	}
+
+	recordCount, convertError := convert.ConvertFiles(converter, inputPaths, command.OutputFile)
+	if convertError != nil {
+		return convertError
+	}
+
+	log.Println(fmt.Sprintf("Converted %d documents", recordCount))
+	return nil
+}
+
+func (command *ConfigShowCommand) Execute(args []string) error {
+	registerError := command.registerManifests()
+	if registerError != nil {
+		return registerError
+	}
+
+	effectiveConfig := config.Config{
+		Tool:    config.OptionValues(command.ToolOptions),
+		Split:   config.OptionValues(command.SplitOptions),
+		Runtime: config.OptionValues(command.RuntimeOptions),
+		Execute: config.OptionValues(command.ExecuteOptions),
+		Reduce:  config.OptionValues(command.ReduceOptions),
+		Tools:   make(map[string]tools.ToolConfig),
+	}
+
+	// the settings of the selected tool, or of every tool
+	toolNames := tools.ToolNames()
+	if len(command.Tool) > 0 {
+		toolNames = []string{command.Tool}
+	}
+	for _, toolName := range toolNames {
+		toolConfig, toolConfigError := tools.GetToolConfig(toolName)
+		if toolConfigError != nil {
+			return toolConfigError
+		}
+		effectiveConfig.Tools[toolName] = *toolConfig
+	}
+
+	configBytes, marshalError := yaml.Marshal(effectiveConfig)
+	if marshalError != nil {
+		return marshalError
+	}
+
+	fmt.Print(string(configBytes))
+	return nil
+}
+
+// registerManifests registers the tools declared in manifests and applies the tools section of the config file
+func (toolOpts ToolOptions) registerManifests() error {
+	if len(toolOpts.ManifestDir) > 0 {
+		loadError := tools.LoadManifestDir(toolOpts.ManifestDir)
+		if loadError != nil {
+			return loadError
+		}
+	}
+
+	if pipelineConfig != nil {
+		return pipelineConfig.ConfigureTools()
+	}
+	return nil
+}
+
+// runID is the id given by --runid, or else the one of the workdir, so that a resumed run and clean find the containers
+// of the run again
+func (runtimeOpts RuntimeOptions) runID(workDir string) string {
+	if len(runtimeOpts.RunID) > 0 {
+		return runtimeOpts.RunID
+	}
+
+	absoluteWorkDir, absError := filepath.Abs(workDir)
+	if absError != nil {
+		absoluteWorkDir = workDir
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(absoluteWorkDir)))[:8]
+}
+
+// checkSplit fails when there are no task folders to work on
+func (toolOpts ToolOptions) checkSplit() error {
+	if misc.TaskFoldersExist(toolOpts.Workdir, toolOpts.Tool) == false {
+		return errors.New(fmt.Sprintf("No task folders in %s, split the input docs first", path.Join(toolOpts.Workdir, toolOpts.Tool)))
+	}
+	return nil
+}
+
+// reportDir is the directory of the failure report, the workdir unless an output dir is given
+func (toolOpts ToolOptions) reportDir(outputDir string) string {
+	if len(outputDir) > 0 {
+		return outputDir
+	}
+	return toolOpts.Workdir
+}
+
+func (executeOpts ExecuteOptions) executeOptions(workDir string) tools.ExecuteOptions {
+	return tools.ExecuteOptions{
+		WorkDir:          workDir,
+		NumParallelTasks: executeOpts.NumberOfTask,
+		Resume:           bool(executeOpts.Resume),
+		RetryPolicy: tools.RetryPolicy{
+			MaxRetries:     executeOpts.Retries,
+			InitialBackoff: executeOpts.RetryBackoff,
+			MaxBackoff:     executeOpts.MaxBackoff,
+			StageRetries:   executeOpts.StageRetries,
+		},
+		StageTimeouts: tools.StageTimeouts{
+			Default: executeOpts.StageTimeout,
+			Stages:  executeOpts.StageTimeouts,
+		},
+		RunTimeout: executeOpts.RunTimeout,
+		Aligner:    executeOpts.Aligner,
+	}
+}
+
+func split(toolOpts ToolOptions, splitOpts SplitOptions, collectionType string) error {
+	inputDocPaths, inputDocsError := misc.ResolveInputDocs(splitOpts.InputDocs)
+	if inputDocsError != nil {
+		return inputDocsError
+	}
+
+	return misc.SplitInputDocs(inputDocPaths, toolOpts.Workdir, toolOpts.Tool, splitOpts.splitOptions(collectionType))
+}
+
+func (splitOpts SplitOptions) splitOptions(collectionType string) misc.SplitOptions {
+	return misc.SplitOptions{
+		LinesPerTask:     splitOpts.LinesPerTask,
+		CharsPerTask:     splitOpts.CharsPerTask,
+		GroupKey:         splitOpts.GroupBy,
+		Validate:         bool(splitOpts.Validate),
+		CollectionType:   collectionType,
+		MaxRejectedRatio: splitOpts.MaxRejected,
+	}
+}
+
+func run(toolOpts ToolOptions, runtimeOpts RuntimeOptions, executeOptions tools.ExecuteOptions, reportDir string) error {
+	// create the backend that runs the tools
+	containerRuntime, runtimeError := createContainerRuntime(runtimeOpts)
+	if runtimeError != nil {
+		return runtimeError
+	}
+
+	// Ctrl-C stops the run, its containers are removed and its checkpoint is saved
+	ctx, stopInterrupts := interruptContext()
+	defer stopInterrupts()
+	executeOptions.Context = ctx
+	executeOptions.RunID = runtimeOpts.runID(toolOpts.Workdir)
+
+	// the default run id keeps the subnet of the manifest, the images of a tool may expect their sidecar at its address
+	executeOptions.RunSubnet = len(runtimeOpts.RunID) > 0
+
+	// run tool based on arguments
+	executeError := tools.Execute(containerRuntime, toolOpts.Tool, executeOptions)
+	if executeError != nil {
+		// keep a report of every failed task next to the outputs, also of the tasks that failed before an interrupt
+		failures, isExecuteError := executeError.(*tools.ExecuteError)
+		if interruptedError, isInterruptedError := executeError.(*tools.InterruptedError); isInterruptedError {
+			failures, isExecuteError = interruptedError.Failures, interruptedError.Failures != nil
+		}
+		if isExecuteError {
+			reportError := writeFailureReport(reportDir, failures)
+			if reportError != nil {
+				log.Println(fmt.Sprintf("ERROR: Cannot write the failure report: %s", reportError.Error()))
+			}
+		}
+		return executeError
+	}
+
+	// the report of an earlier failed run no longer applies
+	return removeFailureReport(reportDir)
+}
+
+func reduce(toolOpts ToolOptions, reduceOpts ReduceOptions) error {
+	return tools.Reduce(toolOpts.Workdir, reduceOpts.OutputDir, toolOpts.Tool, reduceOpts.CollectionType, tools.ReduceOptions{SortByDocId: bool(reduceOpts.SortByDocId), Compression: reduceOpts.Compression})
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"itextmine/misc"
+	"itextmine/tools"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v2"
+)
+
+// CONFIG_FLAG is the long name of the flag that points to the config file
+const CONFIG_FLAG string = "config"
+
+// Config is the pipeline config file. The sections hold the values of the command flags by their long name,
+// e.g. numtasks in the execute section, the tools section overrides the settings of the tools.
+type Config struct {
+	Tool    map[string]interface{}      `yaml:"tool,omitempty"`
+	Split   map[string]interface{}      `yaml:"split,omitempty"`
+	Runtime map[string]interface{}      `yaml:"runtime,omitempty"`
+	Execute map[string]interface{}      `yaml:"execute,omitempty"`
+	Reduce  map[string]interface{}      `yaml:"reduce,omitempty"`
+	Tools   map[string]tools.ToolConfig `yaml:"tools,omitempty"`
+}
+
+// Bool is a switch that the command line can also turn off, e.g. --resume=false when the config sets resume.
+// The flags are tagged optional:"yes" optional-value:"true", so that --resume alone still turns it on.
+type Bool bool
+
+func (value *Bool) UnmarshalFlag(flagValue string) error {
+	parsed, parseError := strconv.ParseBool(flagValue)
+	if parseError != nil {
+		return errors.New(fmt.Sprintf("%s is not a valid bool", flagValue))
+	}
+	*value = Bool(parsed)
+	return nil
+}
+
+// section is a section of the config and the group of the command flags it sets
+type section struct {
+	name   string
+	group  string
+	values map[string]interface{}
+}
+
+func LoadConfig(configPath string) (*Config, error) {
+	configBytes, readError := ioutil.ReadFile(configPath)
+	if readError != nil {
+		return nil, readError
+	}
+
+	config := Config{}
+	yamlError := yaml.UnmarshalStrict(configBytes, &config)
+	if yamlError != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", configPath, yamlError.Error()))
+	}
+
+	return &config, nil
+}
+
+// ConfigPath finds the config file in the command line arguments, before they are parsed
+func ConfigPath(args []string) string {
+	for argIndex, arg := range args {
+		if arg == "--" {
+			break
+		} else if arg == "--"+CONFIG_FLAG && argIndex+1 < len(args) {
+			return args[argIndex+1]
+		} else if strings.HasPrefix(arg, "--"+CONFIG_FLAG+"=") {
+			return strings.TrimPrefix(arg, "--"+CONFIG_FLAG+"=")
+		}
+	}
+	return ""
+}
+
+// ApplyDefaults makes the values of the config the defaults of the command flags, so that the flags given on the command line override them
+func (config *Config) ApplyDefaults(parser *flags.Parser) error {
+	for _, configSection := range config.sections() {
+		for _, optionName := range sortedKeys(configSection.values) {
+			// the groups are shared by several commands
+			options := findOptions(parser.Commands(), configSection.group, optionName)
+			if len(options) == 0 {
+				return errors.New(fmt.Sprintf("Unknown option %s in the %s section of the config", optionName, configSection.name))
+			}
+
+			// empty values keep the defaults of the flags
+			defaults := defaultValues(configSection.values[optionName])
+			if len(defaults) == 0 {
+				continue
+			}
+
+			for _, option := range options {
+				checkError := checkValues(option, defaults)
+				if checkError != nil {
+					return errors.New(fmt.Sprintf("Invalid value of %s in the %s section of the config: %s", optionName, configSection.name, checkError.Error()))
+				}
+
+				option.Default = defaults
+				option.Required = false
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConfigureTools applies the tools section to the registered tools
+func (config *Config) ConfigureTools() error {
+	toolNames := make([]string, 0, len(config.Tools))
+	for toolName := range config.Tools {
+		toolNames = append(toolNames, toolName)
+	}
+	sort.Strings(toolNames)
+
+	for _, toolName := range toolNames {
+		configureError := tools.ConfigureTool(toolName, config.Tools[toolName])
+		if configureError != nil {
+			return errors.New(fmt.Sprintf("Invalid tools section of the config: %s", configureError.Error()))
+		}
+	}
+
+	return nil
+}
+
+// OptionValues are the values of a group of command flags by their long name, e.g. to print the effective config
+func OptionValues(options interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	optionsValue := reflect.ValueOf(options)
+	for fieldIndex := 0; fieldIndex < optionsValue.NumField(); fieldIndex++ {
+		longName := optionsValue.Type().Field(fieldIndex).Tag.Get("long")
+		if len(longName) == 0 || longName == CONFIG_FLAG {
+			continue
+		}
+
+		// durations are written the way they are given on the command line
+		switch value := optionsValue.Field(fieldIndex).Interface().(type) {
+		case time.Duration:
+			values[longName] = value.String()
+		case map[string]time.Duration:
+			durations := make(map[string]string)
+			for key, duration := range value {
+				durations[key] = duration.String()
+			}
+			values[longName] = durations
+		default:
+			values[longName] = value
+		}
+	}
+	return values
+}
+
+func (config *Config) sections() []section {
+	return []section{
+		{"tool", "Tool Options", config.Tool},
+		{"split", "Split Options", config.Split},
+		{"runtime", "Runtime Options", config.Runtime},
+		{"execute", "Execute Options", config.Execute},
+		{"reduce", "Reduce Options", config.Reduce},
+	}
+}
+
+func findOptions(commands []*flags.Command, groupName string, optionName string) []*flags.Option {
+	options := make([]*flags.Option, 0)
+	for _, command := range commands {
+		group := command.Group.Find(groupName)
+		if group != nil {
+			option := group.FindOptionByLongName(optionName)
+			if option != nil {
+				options = append(options, option)
+			}
+		}
+		options = append(options, findOptions(command.Commands(), groupName, optionName)...)
+	}
+	return options
+}
+
+// defaultValues writes a config value as flag values, lists as repeated flags and maps as key:value flags
+func defaultValues(value interface{}) []string {
+	if value == nil || value == "" {
+		return []string{}
+	}
+
+	defaults := make([]string, 0)
+	reflectValue := reflect.ValueOf(value)
+	switch reflectValue.Kind() {
+	case reflect.Slice:
+		for itemIndex := 0; itemIndex < reflectValue.Len(); itemIndex++ {
+			defaults = append(defaults, fmt.Sprint(reflectValue.Index(itemIndex).Interface()))
+		}
+	case reflect.Map:
+		for _, key := range reflectValue.MapKeys() {
+			defaults = append(defaults, fmt.Sprintf("%v:%v", key.Interface(), reflectValue.MapIndex(key).Interface()))
+		}
+		sort.Strings(defaults)
+	default:
+		defaults = append(defaults, fmt.Sprint(value))
+	}
+	return defaults
+}
+
+// checkValues checks the defaults up front, the parser ignores defaults it cannot convert
+func checkValues(option *flags.Option, defaults []string) error {
+	fieldType := option.Field().Type
+	for _, value := range defaults {
+		if len(option.Choices) > 0 && misc.StringInSlice(value, option.Choices) == false {
+			return errors.New(fmt.Sprintf("%s is not one of %s", value, strings.Join(option.Choices, ", ")))
+		}
+
+		switch fieldType.Kind() {
+		case reflect.Slice:
+			valueError := checkValue(fieldType.Elem(), value)
+			if valueError != nil {
+				return valueError
+			}
+		case reflect.Map:
+			keyValue := strings.SplitN(value, ":", 2)
+			if len(keyValue) != 2 {
+				return errors.New(fmt.Sprintf("%s is not a key:value pair", value))
+			}
+			valueError := checkValue(fieldType.Elem(), keyValue[1])
+			if valueError != nil {
+				return valueError
+			}
+		default:
+			valueError := checkValue(fieldType, value)
+			if valueError != nil {
+				return valueError
+			}
+		}
+	}
+	return nil
+}
+
+func checkValue(valueType reflect.Type, value string) error {
+	var parseError error
+	if valueType == reflect.TypeOf(time.Duration(0)) {
+		_, parseError = time.ParseDuration(value)
+	} else {
+		switch valueType.Kind() {
+		case reflect.Int, reflect.Int64:
+			_, parseError = strconv.ParseInt(value, 10, 64)
+		case reflect.Float64:
+			_, parseError = strconv.ParseFloat(value, 64)
+		case reflect.Bool:
+			_, parseError = strconv.ParseBool(value)
+		}
+	}
+
+	if parseError != nil {
+		return errors.New(fmt.Sprintf("%s is not a valid %s", value, valueType.String()))
+	}
+	return nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,7 @@
+package constants
+
+// labels of the containers and networks created by a run, so that they can be found and removed by run
+const LABEL_RUN_ID string = "itextmine.run"
+const LABEL_TOOL string = "itextmine.tool"
+const LABEL_TASK string = "itextmine.task"
+const LABEL_STAGE string = "itextmine.stage"
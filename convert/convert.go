@@ -0,0 +1,91 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"itextmine/misc"
+	"log"
+	"os"
+	"sort"
+)
+
+// Converter converts a source file to pipeline input documents, one json line per document.
+// It returns the number of documents written.
+type Converter func(reader io.Reader, writer io.Writer) (int, error)
+
+// converters by the source format
+var converters = map[string]Converter{
+	"medline": ConvertMedline,
+	"pmc":     ConvertPmc,
+}
+
+func GetConverter(format string) (Converter, error) {
+	converter, exists := converters[format]
+	if exists == false {
+		return nil, errors.New(fmt.Sprintf("Unknown format %s", format))
+	}
+	return converter, nil
+}
+
+// Formats returns the source formats that can be converted
+func Formats() []string {
+	formats := make([]string, 0, len(converters))
+	for format := range converters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ConvertFiles converts the input files into a single output, compressed when its name ends with .gz or .zst.
+// The output is written to stdout when outputPath is - or empty.
+func ConvertFiles(converter Converter, inputPaths []string, outputPath string) (int, error) {
+	var writer io.Writer = os.Stdout
+	var outputFile io.WriteCloser
+	if len(outputPath) > 0 && outputPath != misc.STDIN_INPUT {
+		var outputFileError error
+		outputFile, outputFileError = misc.CreateOutputFile(outputPath, misc.CompressionOf(outputPath))
+		if outputFileError != nil {
+			return 0, outputFileError
+		}
+		defer outputFile.Close()
+		writer = outputFile
+	}
+
+	recordCount := 0
+	for _, inputPath := range inputPaths {
+		log.Println(fmt.Sprintf("Converting %s", inputPath))
+
+		fileRecordCount, convertError := convertFile(converter, inputPath, writer)
+		recordCount = recordCount + fileRecordCount
+		if convertError != nil {
+			return recordCount, errors.New(fmt.Sprintf("%s: %s", inputPath, convertError.Error()))
+		}
+	}
+
+	// flush the compressed output
+	if outputFile != nil {
+		closeError := outputFile.Close()
+		if closeError != nil {
+			return recordCount, closeError
+		}
+	}
+
+	return recordCount, nil
+}
+
+func convertFile(converter Converter, inputPath string, writer io.Writer) (int, error) {
+	if inputPath == misc.STDIN_INPUT {
+		return converter(os.Stdin, writer)
+	}
+
+	// gz and zst files are decompressed on the fly
+	inputFile, inputFileOpenError := misc.OpenInputFile(inputPath)
+	if inputFileOpenError != nil {
+		return 0, inputFileOpenError
+	}
+	defer inputFile.Close()
+
+	return converter(inputFile, writer)
+}
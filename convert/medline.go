@@ -0,0 +1,148 @@
+package convert
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// MedlineRecord is the pipeline input document of a MEDLINE citation. The text is the title followed by the abstract.
+// Citations without a title have no title offsets, their text starts with the abstract.
+type MedlineRecord struct {
+	DocId    string     `json:"docId"`
+	Title    *Offsets   `json:"title,omitempty"`
+	Text     string     `json:"text"`
+	Sentence []Sentence `json:"sentence"`
+	Mesh     []string   `json:"mesh,omitempty"`
+	Type     []string   `json:"type"`
+}
+
+// pubmedArticle holds the parts of a PubmedArticle element that make up a record
+type pubmedArticle struct {
+	PMID             string         `xml:"MedlineCitation>PMID"`
+	ArticleTitle     xmlText        `xml:"MedlineCitation>Article>ArticleTitle"`
+	AbstractText     []abstractText `xml:"MedlineCitation>Article>Abstract>AbstractText"`
+	PublicationTypes []string       `xml:"MedlineCitation>Article>PublicationTypeList>PublicationType"`
+	MeshHeadings     []string       `xml:"MedlineCitation>MeshHeadingList>MeshHeading>DescriptorName"`
+}
+
+// ConvertMedline converts the PubmedArticle elements of a PubMed XML file to records, one json line per citation
+func ConvertMedline(reader io.Reader, writer io.Writer) (int, error) {
+	decoder := xml.NewDecoder(reader)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	// stream the articles, baseline files do not fit in memory once decoded
+	recordCount := 0
+	for {
+		token, tokenError := decoder.Token()
+		if tokenError == io.EOF {
+			return recordCount, nil
+		} else if tokenError != nil {
+			return recordCount, tokenError
+		}
+
+		startElement, isStartElement := token.(xml.StartElement)
+		if isStartElement == false || startElement.Name.Local != "PubmedArticle" {
+			continue
+		}
+
+		article := pubmedArticle{}
+		decodeError := decoder.DecodeElement(&article, &startElement)
+		if decodeError != nil {
+			return recordCount, decodeError
+		}
+
+		encodeError := encoder.Encode(article.record())
+		if encodeError != nil {
+			return recordCount, encodeError
+		}
+		recordCount = recordCount + 1
+	}
+}
+
+func (article pubmedArticle) record() MedlineRecord {
+	title := normalizeSpace(string(article.ArticleTitle))
+	record := MedlineRecord{
+		DocId:    strings.TrimSpace(article.PMID),
+		Sentence: make([]Sentence, 0),
+		Mesh:     article.MeshHeadings,
+		Type:     article.PublicationTypes,
+	}
+	if len(title) > 0 {
+		record.Title = &Offsets{CharStart: 0, CharEnd: lastCharOffset(title)}
+		record.Text = title
+		record.Sentence = []Sentence{{CharStart: 0, CharEnd: lastCharOffset(title), Index: 0}}
+	}
+
+	// the title is the first sentence, the sections of the abstract follow
+	for _, abstractText := range article.AbstractText {
+		sectionText := normalizeSpace(string(abstractText.Text))
+		if len(abstractText.Label) > 0 {
+			sectionText = abstractText.Label + ": " + sectionText
+		}
+		if len(sectionText) == 0 {
+			continue
+		}
+
+		record.Text, record.Sentence = appendSection(record.Text, record.Sentence, sectionText)
+	}
+
+	if record.Type == nil {
+		record.Type = make([]string, 0)
+	}
+
+	return record
+}
+
+func lastCharOffset(text string) int {
+	if len(text) == 0 {
+		return 0
+	}
+	return utf8.RuneCountInString(text) - 1
+}
+
+// normalizeSpace collapses the line breaks and indentation of the xml
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// abstractText is a section of a structured abstract, or the whole abstract
+type abstractText struct {
+	Label string
+	Text  xmlText
+}
+
+func (abstract *abstractText) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "Label" {
+			abstract.Label = attr.Value
+		}
+	}
+	return abstract.Text.UnmarshalXML(decoder, start)
+}
+
+// xmlText is the text content of an element, including the text of inline markup like <i> and <sup>
+type xmlText string
+
+func (text *xmlText) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	textBuilder := strings.Builder{}
+	for {
+		token, tokenError := decoder.Token()
+		if tokenError != nil {
+			return tokenError
+		}
+
+		switch element := token.(type) {
+		case xml.CharData:
+			textBuilder.Write(element)
+		case xml.EndElement:
+			if element.Name == start.Name {
+				*text = xmlText(textBuilder.String())
+				return nil
+			}
+		}
+	}
+}
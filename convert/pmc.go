@@ -0,0 +1,437 @@
+package convert
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"itextmine/misc"
+	"log"
+	"strings"
+)
+
+const (
+	PMC_TYPE_ABSTRACT  string = "ABS"
+	PMC_TYPE_SECTION   string = "SEC"
+	PMC_TYPE_PARAGRAPH string = "P"
+	PMC_TYPE_FIGURE    string = "FIG"
+)
+
+// PMC_ABSTRACT_ID is the id of the abstract record, the sections of the body are numbered from 1
+const PMC_ABSTRACT_ID int = 0
+
+// PmcRecord is the pipeline input document of a section, paragraph or figure of a PMC article.
+// parent holds the ids of the sections it is nested in, outermost first.
+type PmcRecord struct {
+	DocId       string     `json:"docId"`
+	Parent      []int      `json:"parent"`
+	Sentence    []Sentence `json:"sentence"`
+	Text        string     `json:"text"`
+	ArticleType string     `json:"article_type"`
+	SecType     string     `json:"sec_type"`
+	TitleOffset []int      `json:"title_offset,omitempty"`
+	Pmid        string     `json:"pmid"`
+	Type        string     `json:"type"`
+	Id          int        `json:"id"`
+	Pmcid       string     `json:"pmcid"`
+
+	// the collection spells the figure label fig_lable
+	FigLabel string `json:"fig_lable,omitempty"`
+	FigId    string `json:"fig_id,omitempty"`
+}
+
+// section types by a keyword of the sec-type attribute or the title of the outermost section
+var sectionTypes = []struct {
+	keyword string
+	secType string
+}{
+	{"intro", "introduction"},
+	{"background", "background"},
+	{"method", "methods"},
+	{"result", "results"},
+	{"discussion", "discussion"},
+	{"conclusion", "conclusion"},
+}
+
+// elements that are not part of the text of a paragraph. Figures are records of their own.
+var skippedElements = []string{"fig", "fig-group", "table-wrap", "table-wrap-group", "supplementary-material"}
+
+// xmlNode is an element of a JATS article, or a text node when name is empty
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlNode
+	data     string
+}
+
+// pmcArticle collects the records of an article while its body is walked
+type pmcArticle struct {
+	pmcid       string
+	pmid        string
+	articleType string
+	nextId      int
+	records     []PmcRecord
+}
+
+// ConvertPmc converts the articles of a PMC Open Access JATS XML file to section records, one json line per record
+func ConvertPmc(reader io.Reader, writer io.Writer) (int, error) {
+	decoder := xml.NewDecoder(reader)
+	decoder.Entity = xml.HTMLEntity
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	// bulk files hold several articles
+	recordCount := 0
+	for {
+		token, tokenError := decoder.Token()
+		if tokenError == io.EOF {
+			return recordCount, nil
+		} else if tokenError != nil {
+			return recordCount, tokenError
+		}
+
+		startElement, isStartElement := token.(xml.StartElement)
+		if isStartElement == false || startElement.Name.Local != "article" {
+			continue
+		}
+
+		articleNode, nodeError := readNode(decoder, startElement)
+		if nodeError != nil {
+			return recordCount, nodeError
+		}
+
+		records, recordsError := pmcRecords(articleNode)
+		if recordsError != nil {
+			// an article without a pmcid cannot be keyed, the others are still converted
+			log.Println(fmt.Sprintf("WARN: %s", recordsError.Error()))
+			continue
+		}
+
+		for _, record := range records {
+			encodeError := encoder.Encode(record)
+			if encodeError != nil {
+				return recordCount, encodeError
+			}
+			recordCount = recordCount + 1
+		}
+	}
+}
+
+func pmcRecords(articleNode *xmlNode) ([]PmcRecord, error) {
+	article := pmcArticle{
+		articleType: articleNode.attr("article-type"),
+		nextId:      PMC_ABSTRACT_ID + 1,
+		records:     make([]PmcRecord, 0),
+	}
+
+	articleMeta := articleNode.find("front", "article-meta")
+	if articleMeta != nil {
+		for _, articleId := range articleMeta.childrenNamed("article-id") {
+			switch articleId.attr("pub-id-type") {
+			case "pmc", "pmcid":
+				article.pmcid = normalizeSpace(articleId.textContent())
+			case "pmid":
+				article.pmid = normalizeSpace(articleId.textContent())
+			}
+		}
+	}
+
+	if len(article.pmcid) == 0 {
+		return nil, errors.New(fmt.Sprintf("Skipping article without a pmcid: %s", articleNode.find("front", "article-meta", "title-group", "article-title").textContent()))
+	}
+	if strings.HasPrefix(article.pmcid, "PMC") == false {
+		article.pmcid = "PMC" + article.pmcid
+	}
+
+	article.addAbstract(articleMeta)
+
+	// the body, and the figures that are kept at the end of the article
+	article.addChildren(articleNode.find("body"), make([]int, 0), "")
+	article.addChildren(articleNode.find("floats-group"), make([]int, 0), "")
+
+	return article.records, nil
+}
+
+// addAbstract adds the title and the abstract of the article as a single record
+func (article *pmcArticle) addAbstract(articleMeta *xmlNode) {
+	if articleMeta == nil {
+		return
+	}
+
+	title := sentenceTitle(articleMeta.find("title-group", "article-title").textContent())
+	record := article.newRecord(PMC_TYPE_ABSTRACT, make([]int, 0), "abstract")
+	record.Id = PMC_ABSTRACT_ID
+	record.DocId = fmt.Sprintf("%s-%d", article.pmcid, PMC_ABSTRACT_ID)
+	if len(title) > 0 {
+		record.Text = title
+		record.TitleOffset = []int{0, lastCharOffset(title)}
+		record.Sentence = []Sentence{{CharStart: 0, CharEnd: lastCharOffset(title), Index: 0}}
+	}
+
+	// the abstract of the article, not a graphical abstract or a teaser
+	var abstract *xmlNode
+	for _, abstractNode := range articleMeta.childrenNamed("abstract") {
+		if len(abstractNode.attr("abstract-type")) == 0 {
+			abstract = abstractNode
+			break
+		}
+	}
+
+	if abstract != nil {
+		for _, child := range abstract.children {
+			switch child.name {
+			case "p":
+				record.Text, record.Sentence = appendSection(record.Text, record.Sentence, normalizeSpace(child.paragraphText()))
+			case "sec":
+				// structured abstracts keep the section title as a label, e.g. Background: ...
+				sectionText := make([]string, 0)
+				for _, paragraph := range child.childrenNamed("p") {
+					sectionText = append(sectionText, normalizeSpace(paragraph.paragraphText()))
+				}
+				label := normalizeSpace(child.find("title").textContent())
+				if len(label) > 0 && len(sectionText) > 0 {
+					sectionText[0] = label + ": " + sectionText[0]
+				}
+				for _, paragraphText := range sectionText {
+					record.Text, record.Sentence = appendSection(record.Text, record.Sentence, paragraphText)
+				}
+			}
+		}
+	}
+
+	if len(record.Text) > 0 {
+		article.records = append(article.records, record)
+	}
+}
+
+// addChildren walks the children of an element, parents are the ids of the enclosing sections
+func (article *pmcArticle) addChildren(node *xmlNode, parents []int, secType string) {
+	if node == nil {
+		return
+	}
+
+	for _, child := range node.children {
+		switch child.name {
+		case "":
+			// the text between the elements of the body and of sections is layout
+		case "sec":
+			sectionId := article.nextId
+			article.nextId = article.nextId + 1
+
+			// the outermost section gives the type of everything nested in it
+			title := sentenceTitle(child.find("title").textContent())
+			sectionSecType := secType
+			if len(parents) == 0 {
+				sectionSecType = sectionType(child.attr("sec-type"), title)
+			}
+
+			if len(title) > 0 {
+				record := article.newRecord(PMC_TYPE_SECTION, parents, sectionSecType)
+				record.Id = sectionId
+				record.DocId = fmt.Sprintf("%s-%d", article.pmcid, sectionId)
+				record.Text = title
+				record.TitleOffset = []int{0, lastCharOffset(title)}
+				record.Sentence = []Sentence{{CharStart: 0, CharEnd: lastCharOffset(title), Index: 0}}
+				article.records = append(article.records, record)
+			}
+
+			sectionParents := append(append(make([]int, 0, len(parents)+1), parents...), sectionId)
+			article.addChildren(child, sectionParents, sectionSecType)
+		case "p":
+			article.addText(PMC_TYPE_PARAGRAPH, normalizeSpace(child.paragraphText()), parents, secType)
+
+			// figures can be anchored in the paragraph that cites them
+			article.addFigures(child, parents, secType)
+		case "fig":
+			article.addFigure(child, parents, secType)
+		case "title", "label", "table-wrap", "table-wrap-group", "supplementary-material", "ref-list":
+			// section titles are records of their own, tables and references are not converted
+		default:
+			// lists, boxed text and fig groups hold paragraphs and figures
+			article.addChildren(child, parents, secType)
+		}
+	}
+}
+
+// addFigures adds the figures nested in a paragraph
+func (article *pmcArticle) addFigures(node *xmlNode, parents []int, secType string) {
+	for _, child := range node.children {
+		if child.name == "fig" {
+			article.addFigure(child, parents, secType)
+		} else if child.name != "table-wrap" && child.name != "table-wrap-group" {
+			article.addFigures(child, parents, secType)
+		}
+	}
+}
+
+// addFigure adds a record for the caption of a figure
+func (article *pmcArticle) addFigure(node *xmlNode, parents []int, secType string) {
+	captionText := make([]string, 0)
+	caption := node.find("caption")
+	if caption != nil {
+		for _, captionChild := range caption.children {
+			if captionChild.name == "title" || captionChild.name == "p" {
+				captionText = append(captionText, normalizeSpace(captionChild.paragraphText()))
+			}
+		}
+	}
+
+	record := article.addText(PMC_TYPE_FIGURE, strings.Join(captionText, " "), parents, secType)
+	if record != nil {
+		record.FigLabel = normalizeSpace(node.find("label").textContent())
+		record.FigId = node.attr("id")
+	}
+}
+
+// addText adds a record for the text of a paragraph or figure, empty texts are left out
+func (article *pmcArticle) addText(recordType string, text string, parents []int, secType string) *PmcRecord {
+	if len(text) == 0 {
+		return nil
+	}
+
+	record := article.newRecord(recordType, parents, secType)
+	record.Id = article.nextId
+	record.DocId = fmt.Sprintf("%s-%d", article.pmcid, record.Id)
+	record.Text, record.Sentence = appendSection("", make([]Sentence, 0), text)
+	article.nextId = article.nextId + 1
+
+	article.records = append(article.records, record)
+	return &article.records[len(article.records)-1]
+}
+
+func (article *pmcArticle) newRecord(recordType string, parents []int, secType string) PmcRecord {
+	if len(secType) == 0 {
+		secType = "other"
+	}
+
+	return PmcRecord{
+		Parent:      append(make([]int, 0, len(parents)), parents...),
+		Sentence:    make([]Sentence, 0),
+		ArticleType: article.articleType,
+		SecType:     secType,
+		Pmid:        article.pmid,
+		Type:        recordType,
+		Pmcid:       article.pmcid,
+	}
+}
+
+// sentenceTitle ends a title with a period like the collection does, the period is part of the title offset
+func sentenceTitle(title string) string {
+	title = normalizeSpace(title)
+	if len(title) == 0 || strings.ContainsAny(title[len(title)-1:], ".?!") {
+		return title
+	}
+	return title + "."
+}
+
+// sectionType maps the sec-type attribute, or else the title, of an outermost section to a section type
+func sectionType(secTypeAttr string, title string) string {
+	for _, candidate := range []string{secTypeAttr, title} {
+		candidate = strings.ToLower(candidate)
+		for _, sectionType := range sectionTypes {
+			if strings.Contains(candidate, sectionType.keyword) {
+				return sectionType.secType
+			}
+		}
+	}
+	return "other"
+}
+
+// readNode reads an element and its content into a tree
+func readNode(decoder *xml.Decoder, start xml.StartElement) (*xmlNode, error) {
+	node := &xmlNode{name: start.Name.Local, attrs: start.Attr}
+	for {
+		token, tokenError := decoder.Token()
+		if tokenError != nil {
+			return nil, tokenError
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			child, childError := readNode(decoder, element)
+			if childError != nil {
+				return nil, childError
+			}
+			node.children = append(node.children, child)
+		case xml.CharData:
+			node.children = append(node.children, &xmlNode{data: string(element)})
+		case xml.EndElement:
+			return node, nil
+		}
+	}
+}
+
+func (node *xmlNode) attr(name string) string {
+	if node == nil {
+		return ""
+	}
+	for _, attr := range node.attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// find returns the first descendant along the path of element names, or nil
+func (node *xmlNode) find(names ...string) *xmlNode {
+	current := node
+	for _, name := range names {
+		if current == nil {
+			return nil
+		}
+
+		var next *xmlNode
+		for _, child := range current.children {
+			if child.name == name {
+				next = child
+				break
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func (node *xmlNode) childrenNamed(name string) []*xmlNode {
+	children := make([]*xmlNode, 0)
+	if node == nil {
+		return children
+	}
+	for _, child := range node.children {
+		if child.name == name {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// textContent is the text of the element, including the text of inline markup like <italic> and <xref>
+func (node *xmlNode) textContent() string {
+	return node.collectText(nil)
+}
+
+// paragraphText is the text of a paragraph without the figures and tables anchored in it
+func (node *xmlNode) paragraphText() string {
+	return node.collectText(skippedElements)
+}
+
+func (node *xmlNode) collectText(skipped []string) string {
+	if node == nil {
+		return ""
+	}
+
+	textBuilder := strings.Builder{}
+	node.writeText(&textBuilder, skipped)
+	return textBuilder.String()
+}
+
+func (node *xmlNode) writeText(textBuilder *strings.Builder, skipped []string) {
+	textBuilder.WriteString(node.data)
+	for _, child := range node.children {
+		if misc.StringInSlice(child.name, skipped) == false {
+			child.writeText(textBuilder, skipped)
+		}
+	}
+}
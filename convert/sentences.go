@@ -0,0 +1,127 @@
+package convert
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abbreviations that end with a period without ending the sentence
+var abbreviations = []string{
+	"al", "approx", "ca", "cf", "dr", "e.g", "eq", "etc", "fig", "figs", "i.e", "mr", "mrs", "ms", "no", "nos", "prof", "ref", "refs", "vs", "viz",
+}
+
+// Offsets are the character offsets of a span of the text. charEnd is the offset of the last character of the span.
+type Offsets struct {
+	CharStart int `json:"charStart"`
+	CharEnd   int `json:"charEnd"`
+}
+
+// Sentence is a sentence of the text, in the order of the text
+type Sentence struct {
+	CharStart int `json:"charStart"`
+	CharEnd   int `json:"charEnd"`
+	Index     int `json:"index"`
+}
+
+// SplitSentences returns the sentences of a text, as character offsets relative to the text
+func SplitSentences(text string) []Offsets {
+	runes := []rune(text)
+	sentences := make([]Offsets, 0)
+
+	sentenceStart := -1
+	for runeIndex := 0; runeIndex < len(runes); runeIndex++ {
+		if sentenceStart < 0 {
+			// sentences start at the first non space character
+			if unicode.IsSpace(runes[runeIndex]) == false {
+				sentenceStart = runeIndex
+			}
+			continue
+		}
+
+		if isSentenceEnd(runes, sentenceStart, runeIndex) {
+			sentences = append(sentences, Offsets{CharStart: sentenceStart, CharEnd: runeIndex})
+			sentenceStart = -1
+		}
+	}
+
+	// the text can end without a terminal punctuation
+	if sentenceStart >= 0 {
+		sentenceEnd := len(runes) - 1
+		for unicode.IsSpace(runes[sentenceEnd]) {
+			sentenceEnd = sentenceEnd - 1
+		}
+		sentences = append(sentences, Offsets{CharStart: sentenceStart, CharEnd: sentenceEnd})
+	}
+
+	return sentences
+}
+
+// appendSection appends a section to the text, separated by a space, along with the sentences of the section
+func appendSection(text string, sentences []Sentence, sectionText string) (string, []Sentence) {
+	sectionStart := 0
+	if len(text) > 0 {
+		sectionStart = utf8.RuneCountInString(text) + 1
+		text = text + " "
+	}
+
+	for _, sentenceOffsets := range SplitSentences(sectionText) {
+		sentences = append(sentences, Sentence{
+			CharStart: sectionStart + sentenceOffsets.CharStart,
+			CharEnd:   sectionStart + sentenceOffsets.CharEnd,
+			Index:     len(sentences),
+		})
+	}
+
+	return text + sectionText, sentences
+}
+
+func isSentenceEnd(runes []rune, sentenceStart int, runeIndex int) bool {
+	// closing brackets and quotes after the terminal punctuation belong to the sentence
+	terminalIndex := runeIndex
+	for terminalIndex > sentenceStart && strings.ContainsRune(")]\"'", runes[terminalIndex]) {
+		terminalIndex = terminalIndex - 1
+	}
+	if strings.ContainsRune(".?!", runes[terminalIndex]) == false {
+		return false
+	}
+
+	// the sentence ends before a space followed by the capitalized start of the next sentence
+	nextIndex := runeIndex + 1
+	if nextIndex >= len(runes) {
+		return true
+	}
+	if unicode.IsSpace(runes[nextIndex]) == false {
+		return false
+	}
+	for nextIndex < len(runes) && unicode.IsSpace(runes[nextIndex]) {
+		nextIndex = nextIndex + 1
+	}
+	if nextIndex < len(runes) && unicode.IsUpper(runes[nextIndex]) == false && unicode.IsDigit(runes[nextIndex]) == false && strings.ContainsRune("([\"'", runes[nextIndex]) == false {
+		return false
+	}
+
+	return runes[terminalIndex] != '.' || isAbbreviation(runes, sentenceStart, terminalIndex) == false
+}
+
+func isAbbreviation(runes []rune, sentenceStart int, periodIndex int) bool {
+	// the word in front of the period
+	wordStart := periodIndex
+	for wordStart > sentenceStart && unicode.IsSpace(runes[wordStart-1]) == false && runes[wordStart-1] != '(' {
+		wordStart = wordStart - 1
+	}
+	word := strings.ToLower(string(runes[wordStart:periodIndex]))
+
+	// initials, e.g. J. Smith
+	if len([]rune(word)) == 1 && unicode.IsUpper(runes[wordStart]) {
+		return true
+	}
+
+	for _, abbreviation := range abbreviations {
+		if word == abbreviation {
+			return true
+		}
+	}
+
+	return false
+}
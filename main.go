@@ -1,71 +1,187 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"itextmine/config"
 	"itextmine/misc"
 	"itextmine/tools"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
 
 	"github.com/jessevdk/go-flags"
 )
 
-type Options struct {
-	Tool           string `short:"t" long:"toolname" description:"Name of the text mining tool to run. Options are rlimsp, mirtex" required:"true"`
-	Workdir        string `short:"w" long:"workdir" description:"Full path to the workdir. Please ensure that the user has rw access to the directory" required:"true"`
-	InputDoc       string `short:"i" long:"inputfile" description:"Full path to the input file. Please ensure that the user has read access to the file" required:"true"`
-	OutputDir      string `short:"o" long:"outputdir" description:"Full path to the output directory. Please ensure that the user has rw access to the directory" required:"true"`
-	CollectionType string `short:"c" long:"collection" description:"Type of collection" required:"true"`
-	NumberOfTask   int    `short:"n" long:"numtasks" description:"Number of parallel tasks" default:"10"`
-	LinesPerTask   int    `short:"l" long:"linespertask" description:"Number of lines per tasks" default:"100"`
+// commands of the cli, e.g. itextmine pipeline -t mirtex -w /tmp/workdir -i docs.json -o /tmp/output -c medline
+var commands = []struct {
+	name        string
+	description string
+	data        interface{}
+}{
+	{"pipeline", "Split the input docs, run the tool on every task and reduce the outputs", &PipelineCommand{}},
+	{"split", "Split the input docs into the task folders of the tool", &SplitCommand{}},
+	{"run", "Run the tool on the task folders of the workdir", &RunCommand{}},
+	{"align", "Align the tool outputs of the task folders again", &AlignCommand{}},
+	{"reduce", "Reduce the task outputs of the workdir into the output dir", &ReduceCommand{}},
+	{"clean", "Remove the containers and networks left over by a run", &CleanCommand{}},
+	{"status", "Show the stages done, failed and pending for the tasks of the workdir", &StatusCommand{}},
+	{"convert", "Convert PubMed or PMC XML to input docs", &ConvertCommand{}},
+	{"config", "Show the configuration of the pipeline", &ConfigCommand{}},
 }
 
+// pipelineConfig is the config file given with --config, nil without one
+var pipelineConfig *config.Config
+
 func main() {
-	opts := Options{}
+	parser := flags.NewParser(&GlobalOptions{}, flags.Default)
+	for _, command := range commands {
+		_, addError := parser.AddCommand(command.name, command.description, command.description, command.data)
+		if addError != nil {
+			exitWithError(addError)
+		}
+	}
 
-	// parse arguments
-	_, err := flags.Parse(&opts)
-	if err != nil {
-		panic(err)
+	// failing commands log the error, the parser only prints usage errors
+	parser.CommandHandler = func(command flags.Commander, args []string) error {
+		commandError := command.Execute(args)
+		if tools.IsInterruptedError(commandError) {
+			// the containers were removed and the checkpoint saved, exit like an interrupted process
+			log.Println(fmt.Sprintf("WARN: %s", commandError.Error()))
+			os.Exit(130)
+		} else if commandError != nil {
+			exitWithError(commandError)
+		}
+		return nil
 	}
 
-	// split the input doc
-	splitError := misc.SplitInputDoc(opts.InputDoc, opts.Workdir, opts.Tool, opts.LinesPerTask)
-	if splitError != nil {
-		panic(splitError)
+	// the options without a command run the pipeline, as they did before there were commands
+	args := os.Args[1:]
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		log.Println("WARN: Running without a command is deprecated, use itextmine pipeline")
+		args = append([]string{"pipeline"}, args...)
 	}
 
-	// run tool based on arguments
-	if opts.Tool == "rlimsp" {
-		rlimspError := tools.ExecuteRlimsp(opts.Workdir, opts.NumberOfTask)
-		if rlimspError != nil {
-			panic(rlimspError)
+	// config show prints the configuration, it needs none of the flags
+	optionalFlags(parser.Find("config").Find("show").Group)
+
+	// the values of the config file are the defaults of the flags
+	configPath := config.ConfigPath(args)
+	if len(configPath) > 0 {
+		loadedConfig, configError := config.LoadConfig(configPath)
+		if configError != nil {
+			exitWithError(configError)
+		}
+
+		defaultsError := loadedConfig.ApplyDefaults(parser)
+		if defaultsError != nil {
+			exitWithError(defaultsError)
 		}
-	} else if opts.Tool == "mirtex" {
-		mirtexError := tools.ExecuteMirtex(opts.Workdir, opts.NumberOfTask)
-		if mirtexError != nil {
-			panic(mirtexError)
+		pipelineConfig = loadedConfig
+	}
+
+	// parse arguments and run the command
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		// the usage or the error was printed by the parser
+		if flagsError, isFlagsError := err.(*flags.Error); isFlagsError && flagsError.Type == flags.ErrHelp {
+			os.Exit(0)
 		}
+		os.Exit(1)
+	}
+}
+
+func optionalFlags(group *flags.Group) {
+	for _, option := range group.Options() {
+		option.Required = false
+	}
+	for _, subGroup := range group.Groups() {
+		optionalFlags(subGroup)
+	}
+}
+
+func writeFailureReport(outputDir string, failures *tools.ExecuteError) error {
+	createError := misc.CreateFolderIfNotExists(outputDir)
+	if createError != nil {
+		return createError
+	}
+
+	reportPath := path.Join(outputDir, tools.FAILURE_REPORT_NAME)
+	log.Println(fmt.Sprintf("Writing the failed tasks to %s", reportPath))
+	return failures.WriteReport(reportPath)
+}
+
+func removeFailureReport(outputDir string) error {
+	reportPath := path.Join(outputDir, tools.FAILURE_REPORT_NAME)
+	removeError := os.Remove(reportPath)
+	if removeError != nil && os.IsNotExist(removeError) == false {
+		return removeError
 	}
+	if removeError == nil {
+		log.Println(fmt.Sprintf("Removed the failure report %s of an earlier run", reportPath))
+	}
+	return nil
+}
+
+func exitWithError(err error) {
+	log.Println(fmt.Sprintf("ERROR: %s", err.Error()))
+	os.Exit(1)
+}
 
-	// reduce
-	reduceError := tools.Reduce(opts.Workdir, opts.OutputDir, opts.Tool, opts.CollectionType)
-	if reduceError != nil {
-		panic(reduceError)
+// interruptContext is cancelled on SIGINT or SIGTERM, so that a run removes its containers and saves its checkpoint
+// before it exits. A second signal exits right away. stop restores the default handling of the signals.
+func interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		receivedSignal, received := <-signals
+		if received == false {
+			return
+		}
+		log.Println(fmt.Sprintf("WARN: Received %s, removing the containers and saving the checkpoint. Press Ctrl-C again to exit right away", receivedSignal))
+		cancel()
+
+		_, received = <-signals
+		if received {
+			log.Println("WARN: Exiting before the cleanup is done, remove what is left with itextmine clean")
+			os.Exit(130)
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(signals)
+		cancel()
 	}
 }
 
-func validateArguments(opt Options) error {
-	tools := []string{"rlimsp", "mirtex"}
+func createContainerRuntime(opt RuntimeOptions) (misc.ContainerRuntime, error) {
+	if opt.Backend == "local" {
+		return misc.CreateLocalRuntime(opt.LocalCommands)
+	} else if opt.Backend == "podman" {
+		return misc.CreatePodmanRuntime(opt.PodmanSocket)
+	}
+
+	return misc.CreateDockerRuntime(), nil
+}
 
-	if misc.StringInSlice(opt.Tool, tools) == false {
+func validateArguments(opt PipelineCommand) error {
+	if misc.StringInSlice(opt.Tool, tools.ToolNames()) == false {
 		// check tool names
 		return errors.New(opt.Tool + " is not a valid toolname")
-	} else if len(opt.InputDoc) == 0 {
+	} else if len(opt.InputDocs) == 0 {
 		// check input path
 		return errors.New("Input path cannot be empty")
 	} else if len(opt.OutputDir) == 0 {
 		// check output path
 		return errors.New("Outdir path cannot be empty")
-	} else if len(opt.OutputDir) == 0 {
+	} else if len(opt.Workdir) == 0 {
 		// check workdir
 		return errors.New("Workdir path cannot be empty")
 	} else {
@@ -0,0 +1,115 @@
+package misc
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	COMPRESSION_GZIP string = "gz"
+	COMPRESSION_ZSTD string = "zst"
+)
+
+// CompressionOf returns the compression of a file based on its extension, empty for plain files
+func CompressionOf(filePath string) string {
+	if strings.HasSuffix(filePath, "."+COMPRESSION_GZIP) {
+		return COMPRESSION_GZIP
+	} else if strings.HasSuffix(filePath, "."+COMPRESSION_ZSTD) {
+		return COMPRESSION_ZSTD
+	}
+	return ""
+}
+
+// compressedReader closes the decompressor together with the file
+type compressedReader struct {
+	io.Reader
+	closeReader func()
+	file        *os.File
+}
+
+func (reader *compressedReader) Close() error {
+	if reader.closeReader != nil {
+		reader.closeReader()
+	}
+	return reader.file.Close()
+}
+
+// OpenInputFile opens a plain, .gz or .zst file and decompresses it on the fly
+func OpenInputFile(filePath string) (io.ReadCloser, error) {
+	file, openError := os.Open(filePath)
+	if openError != nil {
+		return nil, openError
+	}
+
+	switch CompressionOf(filePath) {
+	case COMPRESSION_GZIP:
+		gzipReader, gzipError := gzip.NewReader(file)
+		if gzipError != nil {
+			file.Close()
+			return nil, errors.New(fmt.Sprintf("%s: %s", filePath, gzipError.Error()))
+		}
+		return &compressedReader{Reader: gzipReader, closeReader: func() { gzipReader.Close() }, file: file}, nil
+	case COMPRESSION_ZSTD:
+		zstdReader, zstdError := zstd.NewReader(file)
+		if zstdError != nil {
+			file.Close()
+			return nil, errors.New(fmt.Sprintf("%s: %s", filePath, zstdError.Error()))
+		}
+		return &compressedReader{Reader: zstdReader, closeReader: zstdReader.Close, file: file}, nil
+	}
+
+	return file, nil
+}
+
+// compressedWriter flushes the compressor before the file is closed
+type compressedWriter struct {
+	io.WriteCloser
+	file   *os.File
+	closed bool
+}
+
+func (writer *compressedWriter) Close() error {
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+
+	closeError := writer.WriteCloser.Close()
+	if closeError != nil {
+		writer.file.Close()
+		return closeError
+	}
+	return writer.file.Close()
+}
+
+// CreateOutputFile creates a file that is compressed with gz or zst, or left plain when compression is empty
+func CreateOutputFile(filePath string, compression string) (io.WriteCloser, error) {
+	if compression != "" && compression != COMPRESSION_GZIP && compression != COMPRESSION_ZSTD {
+		return nil, errors.New(fmt.Sprintf("Unknown compression %s", compression))
+	}
+
+	file, createError := os.Create(filePath)
+	if createError != nil {
+		return nil, createError
+	}
+
+	switch compression {
+	case COMPRESSION_GZIP:
+		return &compressedWriter{WriteCloser: gzip.NewWriter(file), file: file}, nil
+	case COMPRESSION_ZSTD:
+		zstdWriter, zstdError := zstd.NewWriter(file)
+		if zstdError != nil {
+			file.Close()
+			return nil, zstdError
+		}
+		return &compressedWriter{WriteCloser: zstdWriter, file: file}, nil
+	}
+
+	return file, nil
+}
@@ -0,0 +1,43 @@
+package misc
+
+import (
+	"context"
+	"io"
+)
+
+// ContainerSpec describes a container to be created by a ContainerRuntime
+type ContainerSpec struct {
+	Name      string
+	Image     string
+	Binds     []string
+	Network   string
+	IPAddress string
+	Aliases   []string
+	Labels    map[string]string
+}
+
+// ContainerRuntime covers the container and network operations used by the pipeline
+type ContainerRuntime interface {
+	ContainerCreate(ctx context.Context, spec ContainerSpec) (string, error)
+	ContainerStart(ctx context.Context, containerID string) error
+	ContainerWait(ctx context.Context, containerID string) (int64, error)
+	ContainerRemove(ctx context.Context, containerID string) error
+	ContainerLogs(ctx context.Context, containerID string, logWriter io.Writer) error
+	ContainerList(ctx context.Context, namePattern string) ([]string, error)
+	ContainerListByLabels(ctx context.Context, labels map[string]string) ([]string, error)
+	ImagePull(ctx context.Context, imageName string) error
+	NetworkCreate(ctx context.Context, networkName string, subnet string, labels map[string]string) (string, error)
+	NetworkRemove(ctx context.Context, networkID string) error
+	NetworkList(ctx context.Context, namePattern string) ([]string, error)
+	NetworkListByLabels(ctx context.Context, labels map[string]string) ([]string, error)
+}
+
+// HasLabels is true when the labels hold all the wanted labels
+func HasLabels(labels map[string]string, wantedLabels map[string]string) bool {
+	for key, value := range wantedLabels {
+		if labelValue, exists := labels[key]; exists == false || labelValue != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,268 @@
+package misc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/docker/pkg/term"
+)
+
+// DockerRuntime runs the containers on a docker daemon or on a daemon with a docker compatible api
+type DockerRuntime struct {
+	dockerClient *client.Client
+
+	// options appended to every bind mount, e.g. z to relabel the task files for podman on selinux hosts
+	bindOptions string
+}
+
+func NewDockerRuntime(dockerClient *client.Client) *DockerRuntime {
+	return &DockerRuntime{dockerClient: dockerClient}
+}
+
+func CreateDockerRuntime() ContainerRuntime {
+	return NewDockerRuntime(CreateDockerClient())
+}
+
+func (runtime *DockerRuntime) ContainerCreate(ctx context.Context, spec ContainerSpec) (string, error) {
+	// container config
+	containerConfig := container.Config{
+		Image:  spec.Image,
+		Labels: spec.Labels,
+	}
+
+	// host config
+	hostConfig := container.HostConfig{
+		Binds: runtime.binds(spec.Binds),
+	}
+
+	// network config
+	var networkConfig *network.NetworkingConfig
+	if len(spec.Network) > 0 {
+		endpointSettings := network.EndpointSettings{
+			IPAddress: spec.IPAddress,
+			Aliases:   spec.Aliases,
+		}
+
+		// fixed addresses are only honored through the ipam config
+		if len(spec.IPAddress) > 0 {
+			endpointSettings.IPAMConfig = &network.EndpointIPAMConfig{
+				IPv4Address: spec.IPAddress,
+			}
+		}
+
+		networkConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				spec.Network: &endpointSettings,
+			},
+		}
+	}
+
+	containerCreateResponse, containerCreateError := runtime.dockerClient.ContainerCreate(ctx,
+		&containerConfig,
+		&hostConfig,
+		networkConfig,
+		spec.Name)
+
+	if containerCreateError != nil {
+		return "", containerCreateError
+	}
+
+	return containerCreateResponse.ID, nil
+}
+
+func (runtime *DockerRuntime) ContainerStart(ctx context.Context, containerID string) error {
+	return runtime.dockerClient.ContainerStart(ctx, containerID, types.ContainerStartOptions{})
+}
+
+func (runtime *DockerRuntime) ContainerWait(ctx context.Context, containerID string) (int64, error) {
+	return runtime.dockerClient.ContainerWait(ctx, containerID)
+}
+
+func (runtime *DockerRuntime) ContainerRemove(ctx context.Context, containerID string) error {
+	return runtime.dockerClient.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true})
+}
+
+func (runtime *DockerRuntime) ContainerLogs(ctx context.Context, containerID string, logWriter io.Writer) error {
+	reader, logsError := runtime.dockerClient.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if logsError != nil {
+		return logsError
+	}
+	defer reader.Close()
+
+	// stdout and stderr are multiplexed on the stream
+	_, copyError := stdcopy.StdCopy(logWriter, logWriter, reader)
+	return copyError
+}
+
+func (runtime *DockerRuntime) ContainerList(ctx context.Context, namePattern string) ([]string, error) {
+	filterArgs, filterArgsError := filters.ParseFlag(fmt.Sprintf("name=%s", namePattern), filters.NewArgs())
+	if filterArgsError != nil {
+		return nil, filterArgsError
+	}
+
+	containers, err := runtime.dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filterArgs,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	containerIDs := make([]string, 0, len(containers))
+	for _, container := range containers {
+		containerIDs = append(containerIDs, container.ID)
+	}
+
+	return containerIDs, nil
+}
+
+func (runtime *DockerRuntime) ContainerListByLabels(ctx context.Context, labels map[string]string) ([]string, error) {
+	containers, err := runtime.dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: labelFilters(labels),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	containerIDs := make([]string, 0, len(containers))
+	for _, container := range containers {
+		containerIDs = append(containerIDs, container.ID)
+	}
+
+	return containerIDs, nil
+}
+
+func (runtime *DockerRuntime) ImagePull(ctx context.Context, imageName string) error {
+	// the client only takes full names, images without a registry are on docker hub and images without a tag are latest
+	imageRef, refError := reference.ParseNormalizedNamed(imageName)
+	if refError != nil {
+		return refError
+	}
+
+	reader, pullError := runtime.dockerClient.ImagePull(ctx, imageRef.String(), types.ImagePullOptions{All: false})
+	if pullError != nil {
+		return pullError
+	}
+
+	termFd, isTerm := term.GetFdInfo(os.Stderr)
+	jsonmessage.DisplayJSONMessagesStream(reader, os.Stderr, termFd, isTerm, nil)
+
+	return nil
+}
+
+func (runtime *DockerRuntime) NetworkCreate(ctx context.Context, networkName string, subnet string, labels map[string]string) (string, error) {
+	networkOptions := types.NetworkCreate{
+		CheckDuplicate: false,
+		Driver:         "bridge",
+		Labels:         labels,
+	}
+
+	if len(subnet) > 0 {
+		networkOptions.IPAM = &network.IPAM{
+			Config: []network.IPAMConfig{
+				{
+					Subnet: subnet,
+				},
+			},
+		}
+	}
+
+	networkCreateResponse, err := runtime.dockerClient.NetworkCreate(ctx, networkName, networkOptions)
+	if err != nil {
+		return "", err
+	}
+
+	return networkCreateResponse.ID, nil
+}
+
+func (runtime *DockerRuntime) NetworkRemove(ctx context.Context, networkID string) error {
+	return runtime.dockerClient.NetworkRemove(ctx, networkID)
+}
+
+func (runtime *DockerRuntime) NetworkList(ctx context.Context, namePattern string) ([]string, error) {
+	filterArgs, filterArgsError := filters.ParseFlag(fmt.Sprintf("name=%s", namePattern), filters.NewArgs())
+	if filterArgsError != nil {
+		return nil, filterArgsError
+	}
+
+	networks, err := runtime.dockerClient.NetworkList(ctx, types.NetworkListOptions{
+		Filters: filterArgs,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	networkIDs := make([]string, 0, len(networks))
+	for _, network := range networks {
+		networkIDs = append(networkIDs, network.ID)
+	}
+
+	return networkIDs, nil
+}
+
+func (runtime *DockerRuntime) NetworkListByLabels(ctx context.Context, labels map[string]string) ([]string, error) {
+	networks, err := runtime.dockerClient.NetworkList(ctx, types.NetworkListOptions{
+		Filters: labelFilters(labels),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	networkIDs := make([]string, 0, len(networks))
+	for _, network := range networks {
+		networkIDs = append(networkIDs, network.ID)
+	}
+
+	return networkIDs, nil
+}
+
+func labelFilters(labels map[string]string) filters.Args {
+	// the label filters all have to match
+	filterArgs := filters.NewArgs()
+	for key, value := range labels {
+		filterArgs.Add("label", fmt.Sprintf("%s=%s", key, value))
+	}
+	return filterArgs
+}
+
+func (runtime *DockerRuntime) binds(binds []string) []string {
+	return BindsWithOptions(binds, runtime.bindOptions)
+}
+
+// BindsWithOptions adds the mount options to every bind, next to the options the bind already has.
+func BindsWithOptions(binds []string, options string) []string {
+	if len(options) == 0 {
+		return binds
+	}
+
+	// binds are host:container[:options]
+	optionBinds := make([]string, 0, len(binds))
+	for _, bind := range binds {
+		if strings.Count(bind, ":") > 1 {
+			optionBinds = append(optionBinds, fmt.Sprintf("%s,%s", bind, options))
+		} else {
+			optionBinds = append(optionBinds, fmt.Sprintf("%s:%s", bind, options))
+		}
+	}
+
+	return optionBinds
+}
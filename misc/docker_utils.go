@@ -4,39 +4,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/filters"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/jsonmessage"
-	"github.com/docker/docker/pkg/term"
+	"net"
+	"regexp"
+	"strings"
 )
 
-func RemoveNetwork(ctx context.Context, dockerClient *client.Client, networkName string) error {
+func RemoveNetwork(ctx context.Context, containerRuntime ContainerRuntime, networkName string) error {
 	// check if network name is not empty
 	if len(networkName) == 0 {
 		return errors.New("Network name cannot be empty")
 	}
 
-	filterArgs, filterArgsError := filters.ParseFlag(fmt.Sprintf("name=%s", networkName), filters.NewArgs())
-	if filterArgsError != nil {
-		return filterArgsError
-	}
-
-	networks, err := dockerClient.NetworkList(ctx, types.NetworkListOptions{
-		Filters: filterArgs,
-	})
-
+	networkIDs, err := containerRuntime.NetworkList(ctx, networkName)
 	if err != nil {
 		return err
 	}
 
 	// loop over all these networks and remove them
-	for networkIndex := range networks {
-		network := networks[networkIndex]
-		networkID := network.ID
-		networkRemoveError := dockerClient.NetworkRemove(ctx, networkID)
+	for _, networkID := range networkIDs {
+		networkRemoveError := containerRuntime.NetworkRemove(ctx, networkID)
 		if networkRemoveError != nil {
 			return networkRemoveError
 		}
@@ -45,82 +31,145 @@ func RemoveNetwork(ctx context.Context, dockerClient *client.Client, networkName
 	return nil
 }
 
-func RemoveContainer(ctx context.Context, dockerClient *client.Client, containerName string) error {
+func RemoveContainer(ctx context.Context, containerRuntime ContainerRuntime, containerName string) error {
 	// check if container name is not empty
 	if len(containerName) == 0 {
 		return errors.New("Container name cannot be empty")
 	}
 
-	filterArgs, filterArgsError := filters.ParseFlag(fmt.Sprintf("name=%s", containerName), filters.NewArgs())
-	if filterArgsError != nil {
-		return filterArgsError
+	containerIDs, err := containerRuntime.ContainerList(ctx, containerName)
+	if err != nil {
+		return err
+	}
+
+	// loop over all these containers and remove them
+	for _, containerID := range containerIDs {
+		removeError := containerRuntime.ContainerRemove(ctx, containerID)
+		if removeError != nil {
+			return removeError
+		}
 	}
 
-	containers, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{
-		All:     true,
-		Filters: filterArgs,
-	})
+	return nil
+
+}
+
+// RemoveContainersByLabels removes the containers that have all the labels, e.g. the containers of one run
+func RemoveContainersByLabels(ctx context.Context, containerRuntime ContainerRuntime, labels map[string]string) error {
+	// without labels every container would match
+	if len(labels) == 0 {
+		return errors.New("Labels cannot be empty")
+	}
 
+	containerIDs, err := containerRuntime.ContainerListByLabels(ctx, labels)
 	if err != nil {
 		return err
 	}
 
-	// loop over all these networks and remove them
-	for containerIndex := range containers {
-		container := containers[containerIndex]
-
-		// remove the container
-		removeError := dockerClient.ContainerRemove(ctx, container.ID, types.ContainerRemoveOptions{
-			Force: true,
-		})
-
+	for _, containerID := range containerIDs {
+		removeError := containerRuntime.ContainerRemove(ctx, containerID)
 		if removeError != nil {
 			return removeError
 		}
+	}
 
+	return nil
+}
+
+// RemoveNetworksByLabels removes the networks that have all the labels
+func RemoveNetworksByLabels(ctx context.Context, containerRuntime ContainerRuntime, labels map[string]string) error {
+	if len(labels) == 0 {
+		return errors.New("Labels cannot be empty")
+	}
+
+	networkIDs, err := containerRuntime.NetworkListByLabels(ctx, labels)
+	if err != nil {
+		return err
+	}
+
+	for _, networkID := range networkIDs {
+		networkRemoveError := containerRuntime.NetworkRemove(ctx, networkID)
+		if networkRemoveError != nil {
+			return networkRemoveError
+		}
 	}
 
 	return nil
+}
 
+// RuntimeError is an error of the container runtime rather than of a tool, e.g. a daemon that cannot be reached
+type RuntimeError struct {
+	Err error
 }
 
-func CheckIfNetworkExists(ctx context.Context, dockerClient *client.Client, networkName string) (bool, string, error) {
-	// check if network name is not empty
-	if len(networkName) == 0 {
-		return false, "", errors.New("Network name cannot be empty")
+func (runtimeError *RuntimeError) Error() string {
+	return runtimeError.Err.Error()
+}
+
+func (runtimeError *RuntimeError) Unwrap() error {
+	return runtimeError.Err
+}
+
+// WrapRuntimeError marks an error returned by the container runtime, nil stays nil
+func WrapRuntimeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RuntimeError{Err: err}
+}
+
+func CreateContainer(ctx context.Context, containerRuntime ContainerRuntime, spec ContainerSpec) (string, error) {
+	containerID, containerCreateError := containerRuntime.ContainerCreate(ctx, spec)
+	if containerCreateError != nil && IsNameConflictError(containerCreateError) {
+		// remove the container left behind by an earlier attempt, so that a retry can use the name
+		RemoveContainer(ctx, containerRuntime, fmt.Sprintf("^/%s$", spec.Name))
 	}
 
-	filterArgs, filterArgsError := filters.ParseFlag(fmt.Sprintf("name=%s", networkName), filters.NewArgs())
-	if filterArgsError != nil {
-		return false, "", filterArgsError
+	return containerID, WrapRuntimeError(containerCreateError)
+}
+
+// matchesContainerName matches a container name as docker lists it, with a leading slash
+func matchesContainerName(nameRegexp *regexp.Regexp, containerName string) bool {
+	return nameRegexp.MatchString("/" + containerName)
+}
+
+func IsNameConflictError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "is already in use")
+}
+
+// IsSubnetInUseError is true when a network cannot be created because its subnet overlaps the one of another network
+func IsSubnetInUseError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Pool overlaps with other one on this address space")
+}
+
+// SubnetsOverlap is true when the two subnets share addresses
+func SubnetsOverlap(subnet string, otherSubnet string) bool {
+	_, ipNet, parseError := net.ParseCIDR(subnet)
+	_, otherIPNet, otherParseError := net.ParseCIDR(otherSubnet)
+	if parseError != nil || otherParseError != nil {
+		return false
 	}
+	return ipNet.Contains(otherIPNet.IP) || otherIPNet.Contains(ipNet.IP)
+}
 
-	networks, err := dockerClient.NetworkList(ctx, types.NetworkListOptions{
-		Filters: filterArgs,
-	})
+func CheckIfNetworkExists(ctx context.Context, containerRuntime ContainerRuntime, networkName string) (bool, string, error) {
+	// check if network name is not empty
+	if len(networkName) == 0 {
+		return false, "", errors.New("Network name cannot be empty")
+	}
 
+	networkIDs, err := containerRuntime.NetworkList(ctx, networkName)
 	if err != nil {
 		return false, "", err
 	}
 
-	// loop over all these networks and remove them
-	if len(networks) > 0 {
-		network := networks[0]
-		networkID := network.ID
-		return true, networkID, nil
+	if len(networkIDs) > 0 {
+		return true, networkIDs[0], nil
 	} else {
 		return false, "", nil
 	}
 }
 
-func PullImage(ctx context.Context, dockerClient *client.Client, imageName string) error {
-	reader, pullError := dockerClient.ImagePull(ctx, fmt.Sprintf("docker.io/%s", imageName), types.ImagePullOptions{All: true})
-	if pullError != nil {
-		return pullError
-	}
-
-	termFd, isTerm := term.GetFdInfo(os.Stderr)
-	jsonmessage.DisplayJSONMessagesStream(reader, os.Stderr, termFd, isTerm, nil)
-
-	return nil
+func PullImage(ctx context.Context, containerRuntime ContainerRuntime, imageName string) error {
+	return WrapRuntimeError(containerRuntime.ImagePull(ctx, imageName))
 }
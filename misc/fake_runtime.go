@@ -0,0 +1,319 @@
+package misc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FakeRunner is called when a fake container is started. mounts maps the container paths of the binds to host paths.
+// What the runner writes to logs is handed out by ContainerLogs and the returned exit code by ContainerWait.
+type FakeRunner func(spec ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error)
+
+// FakeRuntime is an in-memory ContainerRuntime for tests. Containers run the FakeRunner registered for their image.
+type FakeRuntime struct {
+	mutex      sync.Mutex
+	runners    map[string]FakeRunner
+	containers map[string]*fakeContainer
+	networks   map[string]string
+	nextID     int
+
+	// labels and subnets of the networks, by network id
+	networkLabels  map[string]map[string]string
+	networkSubnets map[string]string
+
+	// history of the calls, for assertions in tests
+	PulledImages      []string
+	CreatedContainers []ContainerSpec
+	CreatedNetworks   []string
+}
+
+type fakeContainer struct {
+	spec     ContainerSpec
+	started  bool
+	done     chan bool
+	logs     bytes.Buffer
+	exitCode int64
+	runError error
+}
+
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		runners:    make(map[string]FakeRunner),
+		containers: make(map[string]*fakeContainer),
+		networks:   make(map[string]string),
+
+		networkLabels:  make(map[string]map[string]string),
+		networkSubnets: make(map[string]string),
+	}
+}
+
+// SetRunner scripts what the containers of an image do. Containers of images without a runner exit with 0.
+func (fake *FakeRuntime) SetRunner(imageName string, runner FakeRunner) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.runners[imageName] = runner
+}
+
+// ContainerNames returns the names of the containers that have not been removed
+func (fake *FakeRuntime) ContainerNames() []string {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	containerNames := make([]string, 0, len(fake.containers))
+	for _, container := range fake.containers {
+		containerNames = append(containerNames, container.spec.Name)
+	}
+	sort.Strings(containerNames)
+	return containerNames
+}
+
+// NetworkNames returns the names of the networks that have not been removed
+func (fake *FakeRuntime) NetworkNames() []string {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	networkNames := make([]string, 0, len(fake.networks))
+	for _, networkName := range fake.networks {
+		networkNames = append(networkNames, networkName)
+	}
+	sort.Strings(networkNames)
+	return networkNames
+}
+
+func (fake *FakeRuntime) ContainerCreate(ctx context.Context, spec ContainerSpec) (string, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	// container names are unique, as they are with docker
+	for _, container := range fake.containers {
+		if len(spec.Name) > 0 && container.spec.Name == spec.Name {
+			return "", errors.New(fmt.Sprintf("Conflict. The container name \"/%s\" is already in use", spec.Name))
+		}
+	}
+
+	fake.nextID = fake.nextID + 1
+	containerID := fmt.Sprintf("fake-container-%d", fake.nextID)
+	fake.containers[containerID] = &fakeContainer{spec: spec, done: make(chan bool)}
+	fake.CreatedContainers = append(fake.CreatedContainers, spec)
+
+	return containerID, nil
+}
+
+func (fake *FakeRuntime) ContainerStart(ctx context.Context, containerID string) error {
+	fake.mutex.Lock()
+	container, exists := fake.containers[containerID]
+	if exists == false {
+		fake.mutex.Unlock()
+		return errors.New(fmt.Sprintf("No such container: %s", containerID))
+	}
+	runner := fake.runners[container.spec.Image]
+	container.started = true
+	fake.mutex.Unlock()
+
+	// run the scripted container in the background, as docker does
+	go func() {
+		defer close(container.done)
+		if runner == nil {
+			return
+		}
+
+		exitCode, runError := runner(container.spec, bindMounts(container.spec.Binds), &container.logs)
+
+		fake.mutex.Lock()
+		container.exitCode = exitCode
+		container.runError = runError
+		fake.mutex.Unlock()
+	}()
+
+	return nil
+}
+
+func (fake *FakeRuntime) ContainerWait(ctx context.Context, containerID string) (int64, error) {
+	fake.mutex.Lock()
+	container, exists := fake.containers[containerID]
+	fake.mutex.Unlock()
+
+	if exists == false {
+		return -1, errors.New(fmt.Sprintf("No such container: %s", containerID))
+	}
+
+	if container.started == false {
+		return -1, errors.New(fmt.Sprintf("Container %s is not running", containerID))
+	}
+
+	// wait for the runner or give up with the context
+	select {
+	case <-container.done:
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return container.exitCode, container.runError
+}
+
+func (fake *FakeRuntime) ContainerRemove(ctx context.Context, containerID string) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if _, exists := fake.containers[containerID]; exists == false {
+		return errors.New(fmt.Sprintf("No such container: %s", containerID))
+	}
+
+	delete(fake.containers, containerID)
+	return nil
+}
+
+func (fake *FakeRuntime) ContainerLogs(ctx context.Context, containerID string, logWriter io.Writer) error {
+	fake.mutex.Lock()
+	container, exists := fake.containers[containerID]
+	fake.mutex.Unlock()
+
+	if exists == false {
+		return errors.New(fmt.Sprintf("No such container: %s", containerID))
+	}
+
+	// the logs are complete once the runner is done
+	select {
+	case <-container.done:
+	default:
+		return errors.New(fmt.Sprintf("Container %s is still running", containerID))
+	}
+
+	_, writeError := logWriter.Write(container.logs.Bytes())
+	return writeError
+}
+
+func (fake *FakeRuntime) ContainerList(ctx context.Context, namePattern string) ([]string, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	// docker matches the name filter as a regular expression
+	nameRegexp, regexpError := regexp.Compile(namePattern)
+	if regexpError != nil {
+		return nil, regexpError
+	}
+
+	containerIDs := make([]string, 0)
+	for containerID, container := range fake.containers {
+		if matchesContainerName(nameRegexp, container.spec.Name) {
+			containerIDs = append(containerIDs, containerID)
+		}
+	}
+	sort.Strings(containerIDs)
+
+	return containerIDs, nil
+}
+
+func (fake *FakeRuntime) ContainerListByLabels(ctx context.Context, labels map[string]string) ([]string, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	containerIDs := make([]string, 0)
+	for containerID, container := range fake.containers {
+		if HasLabels(container.spec.Labels, labels) {
+			containerIDs = append(containerIDs, containerID)
+		}
+	}
+	sort.Strings(containerIDs)
+
+	return containerIDs, nil
+}
+
+func (fake *FakeRuntime) ImagePull(ctx context.Context, imageName string) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.PulledImages = append(fake.PulledImages, imageName)
+	return nil
+}
+
+func (fake *FakeRuntime) NetworkCreate(ctx context.Context, networkName string, subnet string, labels map[string]string) (string, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	// like docker, the address pools of the networks cannot overlap
+	for _, networkSubnet := range fake.networkSubnets {
+		if len(subnet) > 0 && SubnetsOverlap(subnet, networkSubnet) {
+			return "", errors.New("Error response from daemon: Pool overlaps with other one on this address space")
+		}
+	}
+
+	fake.nextID = fake.nextID + 1
+	networkID := fmt.Sprintf("fake-network-%d", fake.nextID)
+	fake.networks[networkID] = networkName
+	fake.networkLabels[networkID] = labels
+	fake.networkSubnets[networkID] = subnet
+	fake.CreatedNetworks = append(fake.CreatedNetworks, networkName)
+
+	return networkID, nil
+}
+
+func (fake *FakeRuntime) NetworkRemove(ctx context.Context, networkID string) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if _, exists := fake.networks[networkID]; exists == false {
+		return errors.New(fmt.Sprintf("No such network: %s", networkID))
+	}
+
+	delete(fake.networks, networkID)
+	delete(fake.networkLabels, networkID)
+	delete(fake.networkSubnets, networkID)
+	return nil
+}
+
+func (fake *FakeRuntime) NetworkList(ctx context.Context, namePattern string) ([]string, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	nameRegexp, regexpError := regexp.Compile(namePattern)
+	if regexpError != nil {
+		return nil, regexpError
+	}
+
+	networkIDs := make([]string, 0)
+	for networkID, networkName := range fake.networks {
+		if nameRegexp.MatchString(networkName) {
+			networkIDs = append(networkIDs, networkID)
+		}
+	}
+	sort.Strings(networkIDs)
+
+	return networkIDs, nil
+}
+
+func (fake *FakeRuntime) NetworkListByLabels(ctx context.Context, labels map[string]string) ([]string, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	networkIDs := make([]string, 0)
+	for networkID := range fake.networks {
+		if HasLabels(fake.networkLabels[networkID], labels) {
+			networkIDs = append(networkIDs, networkID)
+		}
+	}
+	sort.Strings(networkIDs)
+
+	return networkIDs, nil
+}
+
+func bindMounts(binds []string) map[string]string {
+	// binds are host:container[:options]
+	mounts := make(map[string]string)
+	for _, bind := range binds {
+		bindParts := strings.Split(bind, ":")
+		if len(bindParts) >= 2 {
+			mounts[bindParts[1]] = bindParts[0]
+		}
+	}
+	return mounts
+}
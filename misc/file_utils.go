@@ -2,14 +2,152 @@ package misc
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// SplitOptions controls how the input doc is split into tasks. A limit of 0 is no limit.
+type SplitOptions struct {
+	LinesPerTask int
+
+	// CharsPerTask closes a task once the text of its documents reaches this number of characters,
+	// so that a task of full text sections takes about as long as a task of abstracts
+	CharsPerTask int
+
+	// GroupKey keeps the records sharing the value of this field in the same task, e.g. pmcid for PMC sections
+	GroupKey string
+
+	// Validate rejects the documents that do not match the schema of CollectionType, they are written to RejectedPath
+	Validate       bool
+	CollectionType string
+
+	// MaxRejectedRatio fails the split when a larger fraction of the documents is rejected. Not checked when 0.
+	MaxRejectedRatio float64
+}
+
+// STDIN_INPUT reads the input doc from stdin
+const STDIN_INPUT string = "-"
+
+// SOURCES_FILE_NAME is written to every task folder next to input.json.
+// Its lines give the input file and line number of the input.json lines.
+const SOURCES_FILE_NAME string = "input.sources.json"
+
+// LineSource is the input file and line number a task line came from.
+// Index is the position of the line among the lines of all the inputs, from 1.
+type LineSource struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Index  int    `json:"index"`
+}
+
+// rejectedDocument is a line of the input doc that failed the validation
+type rejectedDocument struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+	Record string `json:"record"`
+}
+
+func RejectedPath(workdirPath string, toolName string) string {
+	return ToolFilePath(workdirPath, toolName, "rejected.jsonl")
+}
+
+// ToolFilePath is a file of a tool run, <tool>.<fileName> in the workdir
+func ToolFilePath(workdirPath string, toolName string, fileName string) string {
+	// kept next to the tool workdir, the tool workdir only holds task folders
+	return path.Join(workdirPath, fmt.Sprintf("%s.%s", toolName, fileName))
+}
+
 func SplitInputDoc(inputDocPath string, workdirPath string, toolName string, numberOfLines int) error {
+	return SplitInputDocWithOptions(inputDocPath, workdirPath, toolName, SplitOptions{LinesPerTask: numberOfLines})
+}
+
+func SplitInputDocWithOptions(inputDocPath string, workdirPath string, toolName string, options SplitOptions) error {
+	return SplitInputDocs([]string{inputDocPath}, workdirPath, toolName, options)
+}
+
+// ResolveInputDocs expands directories and glob patterns into the input files, in a stable order
+func ResolveInputDocs(inputPatterns []string) ([]string, error) {
+	inputDocPaths := make([]string, 0, len(inputPatterns))
+	for _, inputPattern := range inputPatterns {
+		if inputPattern == STDIN_INPUT {
+			inputDocPaths = append(inputDocPaths, inputPattern)
+			continue
+		}
+
+		// glob patterns
+		if strings.ContainsAny(inputPattern, "*?[") {
+			matches, globError := filepath.Glob(inputPattern)
+			if globError != nil {
+				return nil, globError
+			}
+			if len(matches) == 0 {
+				return nil, errors.New(fmt.Sprintf("No input file matches %s", inputPattern))
+			}
+			sort.Strings(matches)
+			inputDocPaths = append(inputDocPaths, matches...)
+			continue
+		}
+
+		fileInfo, statError := os.Stat(inputPattern)
+		if statError != nil {
+			return nil, statError
+		}
+
+		// the files of a directory, hidden files are skipped
+		if fileInfo.IsDir() {
+			fileInfos, readDirError := ioutil.ReadDir(inputPattern)
+			if readDirError != nil {
+				return nil, readDirError
+			}
+			for _, dirFileInfo := range fileInfos {
+				if dirFileInfo.Mode().IsRegular() && strings.HasPrefix(dirFileInfo.Name(), ".") == false {
+					inputDocPaths = append(inputDocPaths, path.Join(inputPattern, dirFileInfo.Name()))
+				}
+			}
+			continue
+		}
+
+		inputDocPaths = append(inputDocPaths, inputPattern)
+	}
+
+	if len(inputDocPaths) == 0 {
+		return nil, errors.New("No input files")
+	}
+
+	return inputDocPaths, nil
+}
+
+// SplitCounts are the documents and tasks of a split
+type SplitCounts struct {
+	Documents int `json:"documents"`
+	Rejected  int `json:"rejected"`
+	Tasks     int `json:"tasks"`
+
+	// LateRecords joined the task of their group out of the input order
+	LateRecords int `json:"lateRecords"`
+}
+
+// splitHandlers write what the split produces, a dry run only counts it
+type splitHandlers struct {
+	writeTask  func(taskIndex int, lines []string, lineSources []LineSource) error
+	appendLine func(taskIndex int, line string, lineSource LineSource) error
+	reject     func(rejected rejectedDocument) error
+}
+
+// SplitInputDocs splits the input files into one set of tasks, in the order of the files
+func SplitInputDocs(inputDocPaths []string, workdirPath string, toolName string, options SplitOptions) error {
 	// generate the path for workdir
 	toolWorkDirPath := path.Join(workdirPath, toolName)
 
@@ -31,60 +169,243 @@ func SplitInputDoc(inputDocPath string, workdirPath string, toolName string, num
 		return cleanError
 	}
 
-	// open the input doc
-	inputfile, inputFileOpenError := os.Open(inputDocPath)
-	if inputFileOpenError != nil {
-		return inputFileOpenError
+	handlers := splitHandlers{
+		writeTask: func(taskIndex int, lines []string, lineSources []LineSource) error {
+			return writeLines(taskIndex, lines, lineSources, toolWorkDirPath)
+		},
+		appendLine: func(taskIndex int, line string, lineSource LineSource) error {
+			return appendLine(taskIndex, line, lineSource, toolWorkDirPath)
+		},
 	}
-	defer inputfile.Close()
 
-	// Start reading from the file with a reader.
-	scanner := bufio.NewScanner(inputfile)
-	const maxCapacity = 512 * 1024 // 512KB
-	buffer := make([]byte, maxCapacity)
-	scanner.Buffer(buffer, maxCapacity)
+	// rejected documents are written with the reason, so that they can be fixed and resubmitted
+	if options.Validate {
+		rejectedFile, rejectedFileError := os.Create(RejectedPath(workdirPath, toolName))
+		if rejectedFileError != nil {
+			return rejectedFileError
+		}
+		defer rejectedFile.Close()
+
+		rejectedWriter := bufio.NewWriter(rejectedFile)
+		defer rejectedWriter.Flush()
 
+		handlers.reject = func(rejected rejectedDocument) error {
+			rejectedBytes, marshalError := json.Marshal(rejected)
+			if marshalError != nil {
+				return marshalError
+			}
+
+			_, writeError := rejectedWriter.Write(append(rejectedBytes, '\n'))
+			return writeError
+		}
+	}
+
+	counts, splitError := splitDocs(inputDocPaths, options, handlers)
+	if splitError != nil {
+		return splitError
+	}
+
+	if counts.Rejected > 0 {
+		log.Println(fmt.Sprintf("WARN: %d of %d documents were rejected, see %s", counts.Rejected, counts.Documents, RejectedPath(workdirPath, toolName)))
+
+		if options.TooManyRejected(counts) {
+			return errors.New(fmt.Sprintf("%d of %d documents were rejected, more than the allowed ratio %g. See %s", counts.Rejected, counts.Documents, options.MaxRejectedRatio, RejectedPath(workdirPath, toolName)))
+		}
+	}
+
+	if counts.LateRecords > 0 {
+		log.Println(fmt.Sprintf("WARN: %d records were not next to the other records of their %s and joined an earlier task", counts.LateRecords, options.GroupKey))
+	}
+
+	return nil
+}
+
+// PlanSplit counts the documents and tasks of a split without writing the task folders
+func PlanSplit(inputDocPaths []string, options SplitOptions) (*SplitCounts, error) {
+	return splitDocs(inputDocPaths, options, splitHandlers{
+		writeTask: func(taskIndex int, lines []string, lineSources []LineSource) error {
+			return nil
+		},
+		appendLine: func(taskIndex int, line string, lineSource LineSource) error {
+			return nil
+		},
+		reject: func(rejected rejectedDocument) error {
+			return nil
+		},
+	})
+}
+
+// TooManyRejected is true when a larger fraction of the documents was rejected than allowed
+func (options SplitOptions) TooManyRejected(counts *SplitCounts) bool {
+	return options.MaxRejectedRatio > 0 && float64(counts.Rejected) > options.MaxRejectedRatio*float64(counts.Documents)
+}
+
+func splitDocs(inputDocPaths []string, options SplitOptions, handlers splitHandlers) (*SplitCounts, error) {
 	// constraints
-	lineIndex := 0
 	taskIndex := 0
+	taskChars := 0
 	linesBuffer := make([]string, 0)
+	sourcesBuffer := make([]LineSource, 0)
 
-	log.Println(fmt.Sprintf("Splitting input file %s", inputDocPath))
+	// task of every group
+	groupTasks := make(map[string]int)
+	counts := SplitCounts{}
 
-	// loop over the input
-	for scanner.Scan() {
-		lineIndex = lineIndex + 1
-		linesBuffer = append(linesBuffer, scanner.Text())
+	// write the buffered lines as the next task
+	flushTask := func() error {
+		writeError := handlers.writeTask(taskIndex, linesBuffer, sourcesBuffer)
+		if writeError != nil {
+			return writeError
+		}
 
-		if lineIndex%numberOfLines == 0 {
-			// rest line index to 0
-			lineIndex = 0
+		// increment the task index and reset the lines buffer
+		taskIndex = taskIndex + 1
+		taskChars = 0
+		linesBuffer = make([]string, 0)
+		sourcesBuffer = make([]LineSource, 0)
+		return nil
+	}
 
-			// write to disk
-			writeLines(taskIndex, linesBuffer, toolWorkDirPath)
+	splitLine := func(line string, lineSource LineSource) error {
+		counts.Documents = counts.Documents + 1
+		lineSource.Index = counts.Documents
 
-			// increment the task index
-			taskIndex = taskIndex + 1
+		if options.Validate {
+			validateError := ValidateDocument(line, options.CollectionType)
+			if validateError != nil {
+				counts.Rejected = counts.Rejected + 1
+				return handlers.reject(rejectedDocument{Source: lineSource.Source, Line: lineSource.Line, Reason: validateError.Error(), Record: line})
+			}
+		}
 
-			// reset the lines buffer
-			linesBuffer = make([]string, 0)
+		// records without the group key are groups of their own
+		groupKey, hasGroupKey := "", false
+		if len(options.GroupKey) > 0 {
+			groupKey, hasGroupKey = RecordKey(line, options.GroupKey)
+		}
+
+		groupTask, groupExists := groupTasks[groupKey]
+		if hasGroupKey && groupExists && groupTask < taskIndex {
+			// the task of the group was already written
+			counts.LateRecords = counts.LateRecords + 1
+			return handlers.appendLine(groupTask, line, lineSource)
+		}
 
+		lineChars := 0
+		if options.CharsPerTask > 0 {
+			lineChars = TextLength(line)
 		}
+
+		// tasks are only closed between groups, before this document would exceed a limit
+		startsGroup := hasGroupKey == false || groupExists == false
+		linesFull := options.LinesPerTask > 0 && len(linesBuffer) >= options.LinesPerTask
+		charsFull := options.CharsPerTask > 0 && taskChars+lineChars > options.CharsPerTask
+		if startsGroup && len(linesBuffer) > 0 && (linesFull || charsFull) {
+			flushError := flushTask()
+			if flushError != nil {
+				return flushError
+			}
+		}
+
+		linesBuffer = append(linesBuffer, line)
+		sourcesBuffer = append(sourcesBuffer, lineSource)
+		taskChars = taskChars + lineChars
+		if hasGroupKey {
+			groupTasks[groupKey] = taskIndex
+		}
+		return nil
 	}
 
-	scanError := scanner.Err()
-	if scanError != nil {
-		return scanError
+	// loop over the input files
+	for _, inputDocPath := range inputDocPaths {
+		splitError := splitInputFile(inputDocPath, splitLine)
+		if splitError != nil {
+			return nil, splitError
+		}
 	}
 
 	// if the lines buffer is not empty then write the remaining lines
 	if len(linesBuffer) > 0 {
-		writeLines(taskIndex, linesBuffer, toolWorkDirPath)
+		flushError := flushTask()
+		if flushError != nil {
+			return nil, flushError
+		}
+	}
+
+	counts.Tasks = taskIndex
+	return &counts, nil
+}
+
+func splitInputFile(inputDocPath string, splitLine func(line string, lineSource LineSource) error) error {
+	log.Println(fmt.Sprintf("Splitting input file %s", inputDocPath))
+
+	// open the input doc, gz and zst docs are decompressed on the fly
+	var inputfile io.Reader = os.Stdin
+	if inputDocPath != STDIN_INPUT {
+		inputReadCloser, inputFileOpenError := OpenInputFile(inputDocPath)
+		if inputFileOpenError != nil {
+			return inputFileOpenError
+		}
+		defer inputReadCloser.Close()
+		inputfile = inputReadCloser
+	}
+
+	// Start reading from the file with a reader.
+	scanner := bufio.NewScanner(inputfile)
+	const maxCapacity = 512 * 1024 // 512KB
+	buffer := make([]byte, maxCapacity)
+	scanner.Buffer(buffer, maxCapacity)
+
+	// loop over the input
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber = lineNumber + 1
+		splitError := splitLine(scanner.Text(), LineSource{Source: inputDocPath, Line: lineNumber})
+		if splitError != nil {
+			return splitError
+		}
+	}
+
+	scanError := scanner.Err()
+	if scanError != nil {
+		return errors.New(fmt.Sprintf("%s: %s", inputDocPath, scanError.Error()))
 	}
 
 	return nil
 }
 
+// RecordKey returns the value of a field of a document, as a string
+func RecordKey(line string, key string) (string, bool) {
+	document := make(map[string]interface{})
+	unmarshalError := json.Unmarshal([]byte(line), &document)
+	if unmarshalError != nil {
+		return "", false
+	}
+
+	switch value := document[key].(type) {
+	case string:
+		return value, len(value) > 0
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// TextLength returns the number of characters in the text field of a document, or of the whole line if it has no text
+func TextLength(line string) int {
+	document := struct {
+		Text *string `json:"text"`
+	}{}
+
+	unmarshalError := json.Unmarshal([]byte(line), &document)
+	if unmarshalError != nil || document.Text == nil {
+		return utf8.RuneCountInString(line)
+	}
+
+	return utf8.RuneCountInString(*document.Text)
+}
+
 func PathExists(path string) (bool, error) {
 	_, err := os.Stat(path)
 	if err == nil {
@@ -94,6 +415,16 @@ func PathExists(path string) (bool, error) {
 	}
 }
 
+func TaskFoldersExist(workdirPath string, toolName string) bool {
+	// a split run has at least one task folder in the tool workdir
+	taskNames, taskNamesError := GetSubDirNames(path.Join(workdirPath, toolName))
+	if taskNamesError != nil {
+		return false
+	}
+
+	return len(*taskNames) > 0
+}
+
 func GetSubDirNames(dirPath string) (*[]string, error) {
 	dir, dirErr := os.Open(dirPath)
 	if dirErr != nil {
@@ -126,7 +457,67 @@ func CleanDir(dirPath string) error {
 	return nil
 }
 
-func writeLines(taskIndex int, lines []string, toolWorkDirPath string) error {
+// TaskIndex returns the index of a task folder, e.g. 10 for task_10
+func TaskIndex(taskName string) (int, bool) {
+	if strings.HasPrefix(taskName, "task_") == false {
+		return 0, false
+	}
+
+	taskIndex, atoiError := strconv.Atoi(strings.TrimPrefix(taskName, "task_"))
+	if atoiError != nil {
+		return 0, false
+	}
+
+	return taskIndex, true
+}
+
+// SortTaskNames orders the task folders as the input doc was split, task_2 before task_10
+func SortTaskNames(taskNames []string) {
+	sort.SliceStable(taskNames, func(i, j int) bool {
+		return LessTaskName(taskNames[i], taskNames[j])
+	})
+}
+
+func LessTaskName(taskName string, otherTaskName string) bool {
+	taskIndex, isTask := TaskIndex(taskName)
+	otherTaskIndex, isOtherTask := TaskIndex(otherTaskName)
+
+	// anything that is not a task folder goes last
+	if isTask && isOtherTask {
+		return taskIndex < otherTaskIndex
+	} else if isTask != isOtherTask {
+		return isTask
+	}
+	return taskName < otherTaskName
+}
+
+func appendLine(taskIndex int, line string, lineSource LineSource, toolWorkDirPath string) error {
+	taskFolderName := path.Join(toolWorkDirPath, fmt.Sprintf("task_%d", taskIndex))
+	appendError := appendToFile(path.Join(taskFolderName, "input.json"), line+"\n")
+	if appendError != nil {
+		return appendError
+	}
+
+	sourceBytes, marshalError := json.Marshal(lineSource)
+	if marshalError != nil {
+		return marshalError
+	}
+
+	return appendToFile(path.Join(taskFolderName, SOURCES_FILE_NAME), string(sourceBytes)+"\n")
+}
+
+func appendToFile(filePath string, content string) error {
+	file, openError := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, os.FileMode(0666))
+	if openError != nil {
+		return openError
+	}
+	defer file.Close()
+
+	_, writeError := file.WriteString(content)
+	return writeError
+}
+
+func writeLines(taskIndex int, lines []string, lineSources []LineSource, toolWorkDirPath string) error {
 	// create a folder for this task
 	taskFolderName := path.Join(toolWorkDirPath, fmt.Sprintf("task_%d", taskIndex))
 	taskFolderError := os.Mkdir(taskFolderName, os.FileMode(0777))
@@ -161,8 +552,31 @@ func writeLines(taskIndex int, lines []string, toolWorkDirPath string) error {
 		return flushError
 	}
 
-	return nil
+	return writeLineSources(path.Join(taskFolderName, SOURCES_FILE_NAME), lineSources)
+}
+
+func writeLineSources(sourcesFilePath string, lineSources []LineSource) error {
+	sourcesFile, createError := os.Create(sourcesFilePath)
+	if createError != nil {
+		return createError
+	}
+	defer sourcesFile.Close()
+
+	// one source per line of input.json
+	sourcesWriter := bufio.NewWriter(sourcesFile)
+	for _, lineSource := range lineSources {
+		sourceBytes, marshalError := json.Marshal(lineSource)
+		if marshalError != nil {
+			return marshalError
+		}
+
+		_, writeError := sourcesWriter.Write(append(sourceBytes, '\n'))
+		if writeError != nil {
+			return writeError
+		}
+	}
 
+	return sourcesWriter.Flush()
 }
 
 func TouchFile(filePath string) error {
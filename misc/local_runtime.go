@@ -0,0 +1,347 @@
+package misc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LocalCommands maps an image name to the native command run instead of the container.
+// Arguments can reference the bind mounts by their container path, e.g. {/mirtex_workdir/in.json}.
+// An empty command marks a service that is managed outside of the pipeline, e.g. a local mysql.
+type LocalCommands struct {
+	Commands map[string][]string `yaml:"commands"`
+}
+
+// LocalRuntime is a ContainerRuntime that runs the tools as local processes, for hosts without docker
+type LocalRuntime struct {
+	mutex      sync.Mutex
+	commands   map[string][]string
+	containers map[string]*localContainer
+	networks   map[string]string
+	nextID     int
+
+	// labels of the networks, by network id
+	networkLabels map[string]map[string]string
+}
+
+type localContainer struct {
+	spec     ContainerSpec
+	command  *exec.Cmd
+	logs     *bytes.Buffer
+	waitOnce sync.Once
+	done     chan bool
+	waitErr  error
+}
+
+func LoadLocalCommands(commandsPath string) (*LocalCommands, error) {
+	commandsBytes, readError := ioutil.ReadFile(commandsPath)
+	if readError != nil {
+		return nil, readError
+	}
+
+	localCommands := LocalCommands{}
+	yamlError := yaml.UnmarshalStrict(commandsBytes, &localCommands)
+	if yamlError != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", commandsPath, yamlError.Error()))
+	}
+
+	return &localCommands, nil
+}
+
+func NewLocalRuntime(localCommands LocalCommands) *LocalRuntime {
+	return &LocalRuntime{
+		commands:   localCommands.Commands,
+		containers: make(map[string]*localContainer),
+		networks:   make(map[string]string),
+
+		networkLabels: make(map[string]map[string]string),
+	}
+}
+
+func CreateLocalRuntime(commandsPath string) (ContainerRuntime, error) {
+	if len(commandsPath) == 0 {
+		return nil, errors.New("The local backend needs a commands file")
+	}
+
+	localCommands, commandsError := LoadLocalCommands(commandsPath)
+	if commandsError != nil {
+		return nil, commandsError
+	}
+
+	return NewLocalRuntime(*localCommands), nil
+}
+
+func (runtime *LocalRuntime) ContainerCreate(ctx context.Context, spec ContainerSpec) (string, error) {
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+
+	commandTemplate, exists := runtime.commands[spec.Image]
+	if exists == false {
+		return "", errors.New(fmt.Sprintf("No local command configured for image %s", spec.Image))
+	}
+
+	// names are unique, as they are with docker
+	for _, container := range runtime.containers {
+		if len(spec.Name) > 0 && container.spec.Name == spec.Name {
+			return "", errors.New(fmt.Sprintf("Conflict. The container name \"/%s\" is already in use", spec.Name))
+		}
+	}
+
+	// replace the mount placeholders with the host paths
+	var command *exec.Cmd
+	logs := &bytes.Buffer{}
+	if len(commandTemplate) > 0 {
+		mounts := bindMounts(spec.Binds)
+		commandArgs := make([]string, 0, len(commandTemplate))
+		for _, commandArg := range commandTemplate {
+			for mountTarget, mountSource := range mounts {
+				commandArg = strings.Replace(commandArg, fmt.Sprintf("{%s}", mountTarget), mountSource, -1)
+			}
+			commandArgs = append(commandArgs, commandArg)
+		}
+
+		command = exec.Command(commandArgs[0], commandArgs[1:]...)
+
+		// stdout and stderr are kept together, as docker logs shows them
+		command.Stdout = logs
+		command.Stderr = logs
+
+		// run in the task folder
+		if len(spec.Binds) > 0 {
+			command.Dir = path.Dir(strings.Split(spec.Binds[0], ":")[0])
+		}
+	}
+
+	runtime.nextID = runtime.nextID + 1
+	containerID := fmt.Sprintf("local-%d", runtime.nextID)
+	runtime.containers[containerID] = &localContainer{spec: spec, command: command, logs: logs, done: make(chan bool)}
+
+	return containerID, nil
+}
+
+func (runtime *LocalRuntime) ContainerStart(ctx context.Context, containerID string) error {
+	container, containerError := runtime.container(containerID)
+	if containerError != nil {
+		return containerError
+	}
+
+	// services managed outside of the pipeline have nothing to start
+	if container.command == nil {
+		return nil
+	}
+
+	return container.command.Start()
+}
+
+func (runtime *LocalRuntime) ContainerWait(ctx context.Context, containerID string) (int64, error) {
+	container, containerError := runtime.container(containerID)
+	if containerError != nil {
+		return -1, containerError
+	}
+
+	if container.command == nil {
+		return 0, nil
+	}
+
+	// wait for the process in the background, so that a cancelled context can kill it
+	container.waitOnce.Do(func() {
+		go func() {
+			container.waitErr = container.command.Wait()
+			close(container.done)
+		}()
+	})
+
+	select {
+	case <-container.done:
+	case <-ctx.Done():
+		container.command.Process.Kill()
+		return -1, ctx.Err()
+	}
+
+	waitError := container.waitErr
+	if exitError, isExitError := waitError.(*exec.ExitError); isExitError {
+		// a non zero exit is reported through the exit code, as docker does
+		return int64(exitError.ExitCode()), nil
+	} else if waitError != nil {
+		return -1, waitError
+	}
+
+	return 0, nil
+}
+
+func (runtime *LocalRuntime) ContainerRemove(ctx context.Context, containerID string) error {
+	container, containerError := runtime.container(containerID)
+	if containerError != nil {
+		return containerError
+	}
+
+	// kill the process if it is still running
+	if container.command != nil && container.command.Process != nil {
+		select {
+		case <-container.done:
+		default:
+			container.command.Process.Kill()
+		}
+	}
+
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+	delete(runtime.containers, containerID)
+
+	return nil
+}
+
+func (runtime *LocalRuntime) ContainerLogs(ctx context.Context, containerID string, logWriter io.Writer) error {
+	container, containerError := runtime.container(containerID)
+	if containerError != nil {
+		return containerError
+	}
+
+	// the process writes to the buffer until it has exited
+	if container.command != nil {
+		select {
+		case <-container.done:
+		default:
+			return errors.New(fmt.Sprintf("Container %s is still running", containerID))
+		}
+	}
+
+	_, writeError := logWriter.Write(container.logs.Bytes())
+	return writeError
+}
+
+func (runtime *LocalRuntime) ContainerList(ctx context.Context, namePattern string) ([]string, error) {
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+
+	nameRegexp, regexpError := regexp.Compile(namePattern)
+	if regexpError != nil {
+		return nil, regexpError
+	}
+
+	containerIDs := make([]string, 0)
+	for containerID, container := range runtime.containers {
+		if matchesContainerName(nameRegexp, container.spec.Name) {
+			containerIDs = append(containerIDs, containerID)
+		}
+	}
+	sort.Strings(containerIDs)
+
+	return containerIDs, nil
+}
+
+func (runtime *LocalRuntime) ContainerListByLabels(ctx context.Context, labels map[string]string) ([]string, error) {
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+
+	containerIDs := make([]string, 0)
+	for containerID, container := range runtime.containers {
+		if HasLabels(container.spec.Labels, labels) {
+			containerIDs = append(containerIDs, containerID)
+		}
+	}
+	sort.Strings(containerIDs)
+
+	return containerIDs, nil
+}
+
+func (runtime *LocalRuntime) ImagePull(ctx context.Context, imageName string) error {
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+
+	// nothing to pull, but check that the command is installed before the tasks start
+	commandTemplate, exists := runtime.commands[imageName]
+	if exists == false {
+		return errors.New(fmt.Sprintf("No local command configured for image %s", imageName))
+	}
+
+	if len(commandTemplate) > 0 {
+		_, lookPathError := exec.LookPath(commandTemplate[0])
+		if lookPathError != nil {
+			return lookPathError
+		}
+	}
+
+	return nil
+}
+
+func (runtime *LocalRuntime) NetworkCreate(ctx context.Context, networkName string, subnet string, labels map[string]string) (string, error) {
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+
+	// local processes share the host network
+	runtime.nextID = runtime.nextID + 1
+	networkID := fmt.Sprintf("local-network-%d", runtime.nextID)
+	runtime.networks[networkID] = networkName
+	runtime.networkLabels[networkID] = labels
+
+	return networkID, nil
+}
+
+func (runtime *LocalRuntime) NetworkRemove(ctx context.Context, networkID string) error {
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+
+	delete(runtime.networks, networkID)
+	delete(runtime.networkLabels, networkID)
+	return nil
+}
+
+func (runtime *LocalRuntime) NetworkList(ctx context.Context, namePattern string) ([]string, error) {
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+
+	nameRegexp, regexpError := regexp.Compile(namePattern)
+	if regexpError != nil {
+		return nil, regexpError
+	}
+
+	networkIDs := make([]string, 0)
+	for networkID, networkName := range runtime.networks {
+		if nameRegexp.MatchString(networkName) {
+			networkIDs = append(networkIDs, networkID)
+		}
+	}
+	sort.Strings(networkIDs)
+
+	return networkIDs, nil
+}
+
+func (runtime *LocalRuntime) NetworkListByLabels(ctx context.Context, labels map[string]string) ([]string, error) {
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+
+	networkIDs := make([]string, 0)
+	for networkID := range runtime.networks {
+		if HasLabels(runtime.networkLabels[networkID], labels) {
+			networkIDs = append(networkIDs, networkID)
+		}
+	}
+	sort.Strings(networkIDs)
+
+	return networkIDs, nil
+}
+
+func (runtime *LocalRuntime) container(containerID string) (*localContainer, error) {
+	runtime.mutex.Lock()
+	defer runtime.mutex.Unlock()
+
+	container, exists := runtime.containers[containerID]
+	if exists == false {
+		return nil, errors.New(fmt.Sprintf("No such container: %s", containerID))
+	}
+
+	return container, nil
+}
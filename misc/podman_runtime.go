@@ -0,0 +1,58 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/docker/docker/client"
+)
+
+// CreatePodmanRuntime connects to the docker compatible api of a (rootless) podman service.
+// The rootless socket is used when socketPath is empty.
+func CreatePodmanRuntime(socketPath string) (ContainerRuntime, error) {
+	if len(socketPath) == 0 {
+		socketPath = DefaultPodmanSocketPath()
+	}
+
+	// the socket is only there when the podman service is running
+	socketExists, _ := PathExists(socketPath)
+	if socketExists == false {
+		return nil, errors.New(fmt.Sprintf("Podman socket %s does not exist. Start it with: systemctl --user enable --now podman.socket", socketPath))
+	}
+
+	dockerClient, clientError := client.NewClient(fmt.Sprintf("unix://%s", socketPath), client.DefaultVersion, nil, nil)
+	if clientError != nil {
+		return nil, clientError
+	}
+
+	podmanRuntime := NewDockerRuntime(dockerClient)
+
+	// rootless containers can only read the task files once they are relabeled on selinux hosts
+	selinuxEnabled, _ := PathExists("/sys/fs/selinux/enforce")
+	if selinuxEnabled {
+		podmanRuntime.bindOptions = "z"
+	}
+
+	return podmanRuntime, nil
+}
+
+func DefaultPodmanSocketPath() string {
+	return PodmanSocketPath(os.Getenv("XDG_RUNTIME_DIR"), os.Getuid())
+}
+
+// PodmanSocketPath returns the socket of the podman service for a runtime dir and user id.
+func PodmanSocketPath(runtimeDir string, uid int) string {
+	// rootless podman listens in the runtime dir of the user
+	if len(runtimeDir) == 0 {
+		runtimeDir = fmt.Sprintf("/run/user/%d", uid)
+	}
+
+	// root podman listens in /run
+	if uid == 0 {
+		runtimeDir = "/run"
+	}
+
+	return path.Join(runtimeDir, "podman", "podman.sock")
+}
@@ -0,0 +1,110 @@
+package misc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// COLLECTION_PMC is the collection of PMC section records, they carry the pmcid of their article
+const COLLECTION_PMC string = "pmc"
+
+// documentOffsets are character offsets into the text, the end is inclusive: charEnd is the index of the last character
+type documentOffsets struct {
+	CharStart *int `json:"charStart"`
+	CharEnd   *int `json:"charEnd"`
+}
+
+// inputDocument holds the fields of MEDLINE and PMC records that the tools and the alignment rely on
+type inputDocument struct {
+	DocId       interface{}       `json:"docId"`
+	Text        *string           `json:"text"`
+	Title       *documentOffsets  `json:"title"`
+	TitleOffset []int             `json:"title_offset"`
+	Sentence    []documentOffsets `json:"sentence"`
+	Pmcid       *string           `json:"pmcid"`
+}
+
+// ValidateDocument checks a line of the input doc against the schema of the collection
+func ValidateDocument(line string, collectionType string) error {
+	document := inputDocument{}
+	unmarshalError := json.Unmarshal([]byte(line), &document)
+	if unmarshalError != nil {
+		return errors.New(fmt.Sprintf("Invalid json: %s", unmarshalError.Error()))
+	}
+
+	docId, docIdError := DocIdString(document.DocId)
+	if docIdError != nil {
+		return docIdError
+	}
+	if len(docId) == 0 {
+		return errors.New("Missing docId")
+	}
+
+	if document.Text == nil {
+		return errors.New("Missing text")
+	}
+
+	if collectionType == COLLECTION_PMC && (document.Pmcid == nil || len(*document.Pmcid) == 0) {
+		return errors.New("Missing pmcid")
+	}
+
+	// offsets are counted in characters, not bytes
+	textLength := utf8.RuneCountInString(*document.Text)
+
+	if document.Title != nil {
+		offsetsError := document.Title.validate(textLength)
+		if offsetsError != nil {
+			return errors.New(fmt.Sprintf("Invalid title: %s", offsetsError.Error()))
+		}
+	}
+
+	if document.TitleOffset != nil {
+		if len(document.TitleOffset) != 2 {
+			return errors.New("Invalid title_offset: expected [charStart, charEnd]")
+		}
+
+		titleOffsets := documentOffsets{CharStart: &document.TitleOffset[0], CharEnd: &document.TitleOffset[1]}
+		offsetsError := titleOffsets.validate(textLength)
+		if offsetsError != nil {
+			return errors.New(fmt.Sprintf("Invalid title_offset: %s", offsetsError.Error()))
+		}
+	}
+
+	for sentenceIndex, sentence := range document.Sentence {
+		offsetsError := sentence.validate(textLength)
+		if offsetsError != nil {
+			return errors.New(fmt.Sprintf("Invalid sentence %d: %s", sentenceIndex, offsetsError.Error()))
+		}
+	}
+
+	return nil
+}
+
+func (offsets documentOffsets) validate(textLength int) error {
+	if offsets.CharStart == nil || offsets.CharEnd == nil {
+		return errors.New("missing charStart or charEnd")
+	}
+
+	if *offsets.CharStart < 0 || *offsets.CharStart > *offsets.CharEnd || *offsets.CharEnd >= textLength {
+		return errors.New(fmt.Sprintf("offsets %d-%d are outside of the text of length %d", *offsets.CharStart, *offsets.CharEnd, textLength))
+	}
+
+	return nil
+}
+
+// DocIdString is the docId of a record, docIds are strings in the collections but numbers are accepted as well
+func DocIdString(docId interface{}) (string, error) {
+	switch value := docId.(type) {
+	case string:
+		return value, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case nil:
+		return "", errors.New("Missing docId")
+	default:
+		return "", errors.New(fmt.Sprintf("docId must be a string or a number, not %v", value))
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"itextmine/misc"
+	"itextmine/tools"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// alignedRecords reads an aligned output by docId
+func alignedRecords(t *testing.T, filePath string) map[string]map[string]interface{} {
+	content, readError := ioutil.ReadFile(filePath)
+	require.Equal(t, nil, readError, readError)
+
+	records := make(map[string]map[string]interface{})
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		record := make(map[string]interface{})
+		require.Equal(t, nil, json.Unmarshal([]byte(line), &record))
+		records[record["docId"].(string)] = record
+	}
+	return records
+}
+
+// spanText returns the original text covered by a span of an aligned record
+func spanText(record map[string]interface{}, span map[string]interface{}) string {
+	text := []rune(record["text"].(string))
+	return string(text[int(span["charStart"].(float64)) : int(span["charEnd"].(float64))+1])
+}
+
+// Test aligning tool output to the original documents in process
+func TestAlignFiles(t *testing.T) {
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	writeTaskFile(t, workDir, "task_0", "input.json", strings.Join([]string{
+		`{"docId": "1", "text": "miR-21  targets\nPTEN in gliomas."}`,
+		`{"docId": "2", "text": "Let-7 is down-regulated in lung cancer."}`,
+	}, "\n")+"\n")
+
+	// the tool collapsed the white space of doc 1, put an entity of doc 2 at the wrong offsets and reported doc 3
+	writeTaskFile(t, workDir, "task_0", "output.json", strings.Join([]string{
+		`{"docId": "1", "text": "miR-21 targets PTEN in gliomas.", "entity": [{"entityId": "T1", "charStart": 0, "charEnd": 5, "entityText": "miR-21"}, {"entityId": "T2", "charStart": 15, "charEnd": 18, "entityText": "PTEN"}], "relation": [{"relationId": "R1", "trigger": {"charStart": 7, "charEnd": 13}}]}`,
+		`{"docId": "2", "text": "Let-7 is down-regulated in lung cancer.", "entity": [{"entityId": "T1", "charStart": 1, "charEnd": 5, "entityText": "Let-7"}, {"entityId": "T2", "charStart": 27, "charEnd": 37, "entityText": "colon cancer"}]}`,
+		`{"docId": "3", "text": "Not in this task.", "entity": []}`,
+	}, "\n")+"\n")
+
+	taskDir := path.Join(workDir, "mirtex", "task_0")
+	stats, alignError := tools.AlignFiles(path.Join(taskDir, "input.json"), path.Join(taskDir, "output.json"), path.Join(taskDir, "align.json"))
+	require.Equal(t, nil, alignError, alignError)
+	require.Equal(t, tools.AlignStats{Documents: 2, MissingDocuments: 1, Spans: 5, RelocatedSpans: 1, DroppedSpans: 1}, stats)
+
+	records := alignedRecords(t, path.Join(taskDir, "align.json"))
+	require.Equal(t, 2, len(records))
+
+	// offsets point into the original text
+	record := records["1"]
+	require.Equal(t, "miR-21  targets\nPTEN in gliomas.", record["text"])
+	entities := record["entity"].([]interface{})
+	require.Equal(t, "miR-21", spanText(record, entities[0].(map[string]interface{})))
+	require.Equal(t, "PTEN", spanText(record, entities[1].(map[string]interface{})))
+	trigger := record["relation"].([]interface{})[0].(map[string]interface{})["trigger"].(map[string]interface{})
+	require.Equal(t, "targets", spanText(record, trigger))
+
+	// the misplaced entity is relocated, the one that is not in the text is dropped
+	record = records["2"]
+	entities = record["entity"].([]interface{})
+	require.Equal(t, 1, len(entities))
+	require.Equal(t, "Let-7", spanText(record, entities[0].(map[string]interface{})))
+}
+
+// Test the output of the native aligner against the output of the align container, needs docker
+func TestAlignFilesContainer(t *testing.T) {
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+	require.Equal(t, nil, os.MkdirAll(workDir, os.FileMode(0777)))
+
+	// every original document is valid input
+	originalBytes, readError := ioutil.ReadFile("../data/align/original.json")
+	require.Equal(t, nil, readError, readError)
+	for _, line := range strings.Split(strings.TrimSpace(string(originalBytes)), "\n") {
+		require.Equal(t, nil, misc.ValidateDocument(line, "medline"), line)
+	}
+
+	alignedPath := path.Join(workDir, "align.json")
+	stats, alignError := tools.AlignFiles("../data/align/original.json", "../data/align/tool_output.json", alignedPath)
+	require.Equal(t, nil, alignError, alignError)
+	require.Equal(t, tools.AlignStats{Documents: 2, MissingDocuments: 1, UnalignedDocuments: 1, Spans: 5, RelocatedSpans: 1, DroppedSpans: 1}, stats)
+
+	// the container binds absolute paths
+	originalPath, _ := filepath.Abs("../data/align/original.json")
+	toolOutputPath, _ := filepath.Abs("../data/align/tool_output.json")
+	containerAlignedPath, _ := filepath.Abs(path.Join(workDir, "container_align.json"))
+	logPath, _ := filepath.Abs(path.Join(workDir, "align.log"))
+
+	ctx := context.Background()
+	dockerRuntime := misc.CreateDockerRuntime()
+	pullError := dockerRuntime.ImagePull(ctx, "itextmine/align")
+	require.Equal(t, nil, pullError, pullError)
+	containerError := tools.ExecuteAlign(ctx, dockerRuntime, "task_0", originalPath, toolOutputPath, containerAlignedPath, logPath, "aligntest", "itextmine/align", nil)
+	require.Equal(t, nil, containerError, containerError)
+
+	expected, readError := ioutil.ReadFile(containerAlignedPath)
+	require.Equal(t, nil, readError, readError)
+	aligned, readError := ioutil.ReadFile(alignedPath)
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, string(expected), string(aligned))
+}
+
+// Test that the native aligner replaces the align containers
+func TestExecuteNativeAlign(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/mirtex", FakeToolRunner("/mirtex_workdir/in.json", "/mirtex_workdir/out.json"))
+
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	executeError := tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 3, Aligner: tools.ALIGNER_NATIVE})
+	require.Equal(t, nil, executeError, executeError)
+	require.Equal(t, []string{"itextmine/mirtex"}, fakeRuntime.PulledImages)
+	require.Equal(t, 5, len(fakeRuntime.CreatedContainers))
+
+	logExists, _ := misc.PathExists(path.Join(workDir, "mirtex", "task_0", "mirtex-align.log"))
+	require.True(t, logExists)
+
+	reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "medline", tools.ReduceOptions{})
+	require.Equal(t, nil, reduceError, reduceError)
+
+	alignLineCount, alignLineCountError := CountLines(path.Join(outPutDir, "mirtex.medline.align.json"))
+	require.Equal(t, nil, alignLineCountError, alignLineCountError)
+	require.Equal(t, 100, alignLineCount)
+
+	// the unknown aligner is rejected
+	executeError = tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 3, Aligner: "python"})
+	require.NotEqual(t, nil, executeError)
+}
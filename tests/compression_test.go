@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"io"
+	"io/ioutil"
+	"itextmine/misc"
+	"itextmine/tools"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// compressFile writes a compressed copy of a file
+func compressFile(t *testing.T, filePath string, compressedFilePath string) {
+	inputFile, openError := os.Open(filePath)
+	require.Equal(t, nil, openError, openError)
+	defer inputFile.Close()
+
+	outputFile, createError := misc.CreateOutputFile(compressedFilePath, misc.CompressionOf(compressedFilePath))
+	require.Equal(t, nil, createError, createError)
+
+	_, copyError := io.Copy(outputFile, inputFile)
+	require.Equal(t, nil, copyError, copyError)
+	require.Equal(t, nil, outputFile.Close())
+}
+
+// readFile reads a plain or compressed file
+func readFile(t *testing.T, filePath string) string {
+	inputFile, openError := misc.OpenInputFile(filePath)
+	require.Equal(t, nil, openError, openError)
+	defer inputFile.Close()
+
+	content, readError := ioutil.ReadAll(inputFile)
+	require.Equal(t, nil, readError, readError)
+	return string(content)
+}
+
+// Test splitting of gz and zst input docs
+func TestCompressedInputDocSplit(t *testing.T) {
+	inputDoc := "../data/rlimsp/test_split_doc_in.json"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	for _, compression := range []string{misc.COMPRESSION_GZIP, misc.COMPRESSION_ZSTD} {
+		compressedInputDoc := path.Join(workDir, "test_split_doc_in.json."+compression)
+		require.Equal(t, nil, os.MkdirAll(workDir, os.FileMode(0777)))
+		compressFile(t, inputDoc, compressedInputDoc)
+
+		// split the document
+		splitErr := misc.SplitInputDoc(compressedInputDoc, workDir, "rlimsp", 100)
+		require.Equal(t, nil, splitErr, splitErr)
+
+		taskDirNames, taskDirNamesErr := misc.GetSubDirNames(path.Join(workDir, "rlimsp"))
+		require.Equal(t, nil, taskDirNamesErr, taskDirNamesErr)
+		require.Equal(t, 11, len(*taskDirNames), "Improper number of task folders")
+
+		lineCount, lineCountError := CountLines(path.Join(workDir, "rlimsp", "task_10", "input.json"))
+		require.Equal(t, nil, lineCountError, lineCountError)
+		require.Equal(t, 20, lineCount)
+	}
+}
+
+// Test that the reduced outputs can be compressed
+func TestCompressedReduce(t *testing.T) {
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	writeTaskFile(t, workDir, "task_0", "output.json", "{\"docId\": \"1\"}\n")
+	writeTaskFile(t, workDir, "task_0", "align.json", "{\"docId\": \"1\", \"aligned\": true}\n")
+	writeTaskFile(t, workDir, "task_1", "output.json", "{\"docId\": \"2\"}\n")
+	writeTaskFile(t, workDir, "task_1", "align.json", "{\"docId\": \"2\", \"aligned\": true}\n")
+
+	for _, compression := range []string{misc.COMPRESSION_GZIP, misc.COMPRESSION_ZSTD} {
+		reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "medline", tools.ReduceOptions{Compression: compression})
+		require.Equal(t, nil, reduceError, reduceError)
+
+		require.Equal(t, "{\"docId\": \"1\"}\n{\"docId\": \"2\"}\n", readFile(t, path.Join(outPutDir, "mirtex.medline.output.json."+compression)))
+		require.Equal(t, "{\"docId\": \"1\", \"aligned\": true}\n{\"docId\": \"2\", \"aligned\": true}\n", readFile(t, path.Join(outPutDir, "mirtex.medline.align.json."+compression)))
+	}
+}
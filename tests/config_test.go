@@ -0,0 +1,274 @@
+package tests
+
+import (
+	"io/ioutil"
+	"itextmine/config"
+	"itextmine/tools"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/stretchr/testify/require"
+)
+
+// configTestCommand has flag groups named like the groups of the cli
+type configTestCommand struct {
+	ToolOptions struct {
+		Workdir string `short:"w" long:"workdir" required:"true"`
+	} `group:"Tool Options"`
+	ExecuteOptions struct {
+		NumberOfTask  int                      `short:"n" long:"numtasks" default:"10"`
+		Resume        config.Bool              `short:"r" long:"resume" optional:"yes" optional-value:"true"`
+		Retries       int                      `long:"retries" default:"2"`
+		StageTimeouts map[string]time.Duration `long:"stagetimeouts"`
+		Aligner       string                   `long:"aligner" choice:"container" choice:"native"`
+	} `group:"Execute Options"`
+}
+
+func writeConfigFile(t *testing.T, configDir string, configYaml string) *config.Config {
+	configPath := path.Join(configDir, "pipeline.yaml")
+	writeError := ioutil.WriteFile(configPath, []byte(configYaml), 0666)
+	require.Equal(t, nil, writeError, writeError)
+
+	pipelineConfig, configError := config.LoadConfig(configPath)
+	require.Equal(t, nil, configError, configError)
+	return pipelineConfig
+}
+
+func parseWithConfig(pipelineConfig *config.Config, args []string) (*configTestCommand, error) {
+	command := configTestCommand{}
+	parser := flags.NewParser(nil, flags.None)
+	_, addError := parser.AddCommand("run", "run", "run", &command)
+	if addError != nil {
+		return nil, addError
+	}
+
+	defaultsError := pipelineConfig.ApplyDefaults(parser)
+	if defaultsError != nil {
+		return nil, defaultsError
+	}
+
+	_, parseError := parser.ParseArgs(append([]string{"run"}, args...))
+	return &command, parseError
+}
+
+// Test that the config values are the defaults of the flags and that the flags override them
+func TestConfigDefaults(t *testing.T) {
+	configDir, tempDirError := ioutil.TempDir("", "config")
+	require.Equal(t, nil, tempDirError, tempDirError)
+	defer os.RemoveAll(configDir)
+
+	pipelineConfig := writeConfigFile(t, configDir, `
+tool:
+  workdir: /tmp/workdir
+execute:
+  numtasks: 4
+  stagetimeouts:
+    rlimsp: 2h
+    efip: 30m
+  aligner: native
+`)
+
+	// the config values, the required workdir is given by the config
+	command, parseError := parseWithConfig(pipelineConfig, []string{})
+	require.Equal(t, nil, parseError, parseError)
+	require.Equal(t, "/tmp/workdir", command.ToolOptions.Workdir)
+	require.Equal(t, 4, command.ExecuteOptions.NumberOfTask)
+	require.Equal(t, 2, command.ExecuteOptions.Retries)
+	require.Equal(t, map[string]time.Duration{"rlimsp": 2 * time.Hour, "efip": 30 * time.Minute}, command.ExecuteOptions.StageTimeouts)
+	require.Equal(t, "native", command.ExecuteOptions.Aligner)
+
+	// the flags override the config
+	command, parseError = parseWithConfig(pipelineConfig, []string{"-w", "/tmp/other", "-n", "8", "--stagetimeouts", "efip:1h"})
+	require.Equal(t, nil, parseError, parseError)
+	require.Equal(t, "/tmp/other", command.ToolOptions.Workdir)
+	require.Equal(t, 8, command.ExecuteOptions.NumberOfTask)
+	require.Equal(t, map[string]time.Duration{"efip": time.Hour}, command.ExecuteOptions.StageTimeouts)
+
+	// the output of config show can be read again
+	effectiveConfig := config.Config{
+		Tool:    config.OptionValues(command.ToolOptions),
+		Execute: config.OptionValues(command.ExecuteOptions),
+	}
+	require.Equal(t, "1h0m0s", effectiveConfig.Execute["stagetimeouts"].(map[string]string)["efip"])
+	command, parseError = parseWithConfig(&effectiveConfig, []string{})
+	require.Equal(t, nil, parseError, parseError)
+	require.Equal(t, 8, command.ExecuteOptions.NumberOfTask)
+}
+
+// Test that the command line turns off a switch that the config turns on
+func TestConfigBoolOverride(t *testing.T) {
+	configDir, tempDirError := ioutil.TempDir("", "config")
+	require.Equal(t, nil, tempDirError, tempDirError)
+	defer os.RemoveAll(configDir)
+
+	pipelineConfig := writeConfigFile(t, configDir, `
+tool:
+  workdir: /tmp/workdir
+execute:
+  resume: true
+`)
+
+	command, parseError := parseWithConfig(pipelineConfig, []string{})
+	require.Equal(t, nil, parseError, parseError)
+	require.Equal(t, config.Bool(true), command.ExecuteOptions.Resume)
+
+	command, parseError = parseWithConfig(pipelineConfig, []string{"--resume=false"})
+	require.Equal(t, nil, parseError, parseError)
+	require.Equal(t, config.Bool(false), command.ExecuteOptions.Resume)
+
+	// the flag alone still turns the switch on
+	command, parseError = parseWithConfig(writeConfigFile(t, configDir, "tool:\n  workdir: /tmp/workdir\n"), []string{"-r", "-n", "4"})
+	require.Equal(t, nil, parseError, parseError)
+	require.Equal(t, config.Bool(true), command.ExecuteOptions.Resume)
+	require.Equal(t, 4, command.ExecuteOptions.NumberOfTask)
+
+	_, parseError = parseWithConfig(pipelineConfig, []string{"--resume=maybe"})
+	require.NotEqual(t, nil, parseError)
+}
+
+// Test that the config is checked before the flags are parsed
+func TestInvalidConfig(t *testing.T) {
+	configDir, tempDirError := ioutil.TempDir("", "config")
+	require.Equal(t, nil, tempDirError, tempDirError)
+	defer os.RemoveAll(configDir)
+
+	for _, configYaml := range []string{
+		// unknown option
+		"execute:\n  parallel: 4\n",
+		// option of another section
+		"tool:\n  numtasks: 4\n",
+		// invalid number
+		"execute:\n  numtasks: many\n",
+		// invalid duration
+		"execute:\n  stagetimeouts:\n    rlimsp: long\n",
+		// invalid choice
+		"execute:\n  aligner: python\n",
+	} {
+		pipelineConfig := writeConfigFile(t, configDir, configYaml)
+		_, parseError := parseWithConfig(pipelineConfig, []string{"-w", "/tmp/workdir"})
+		require.NotEqual(t, nil, parseError, configYaml)
+	}
+
+	// unknown sections
+	configPath := path.Join(configDir, "pipeline.yaml")
+	writeError := ioutil.WriteFile(configPath, []byte("cluster:\n  nodes: 4\n"), 0666)
+	require.Equal(t, nil, writeError, writeError)
+	_, configError := config.LoadConfig(configPath)
+	require.NotEqual(t, nil, configError)
+}
+
+// Test that the config path is found before the arguments are parsed
+func TestConfigPath(t *testing.T) {
+	require.Equal(t, "pipeline.yaml", config.ConfigPath([]string{"run", "--config", "pipeline.yaml", "-t", "mirtex"}))
+	require.Equal(t, "pipeline.yaml", config.ConfigPath([]string{"run", "--config=pipeline.yaml"}))
+	require.Equal(t, "", config.ConfigPath([]string{"run", "-t", "mirtex"}))
+	require.Equal(t, "", config.ConfigPath([]string{"run", "--", "--config", "pipeline.yaml"}))
+}
+
+// Test overriding the images, names and network settings of a tool
+func TestConfigureTool(t *testing.T) {
+	manifest := tools.ToolManifest{
+		Name:    "configuredtool",
+		Network: &tools.NetworkManifest{Name: "configuredtool", Subnet: "10.0.0.0/16"},
+		Sidecars: []tools.SidecarManifest{
+			{Name: "configuredtool-db", Image: "itextmine/configuredtool-db", IPAddress: "10.0.0.2"},
+		},
+		Stages: []tools.StageManifest{
+			{
+				Name:    "configuredtool",
+				Image:   "itextmine/configuredtool",
+				Network: true,
+				Mounts: []tools.MountManifest{
+					{Source: "input.json", Target: "/workdir/in.json", ReadOnly: true},
+					{Source: "output.json", Target: "/workdir/out.json", Create: true},
+				},
+				Output: "output.json",
+				Align:  &tools.AlignManifest{Output: "align.json"},
+			},
+		},
+	}
+	registerError := tools.RegisterTool(tools.NewManifestTool(manifest))
+	require.Equal(t, nil, registerError, registerError)
+
+	configDir, tempDirError := ioutil.TempDir("", "config")
+	require.Equal(t, nil, tempDirError, tempDirError)
+	defer os.RemoveAll(configDir)
+
+	pipelineConfig := writeConfigFile(t, configDir, `
+tools:
+  configuredtool:
+    tag: "1.2"
+    network:
+      subnet: 10.5.0.0/16
+    sidecars:
+      configuredtool-db:
+        name: medline-db
+        ipAddress: 10.5.0.2
+    stages:
+      configuredtool:
+        containerPrefix: medline-configuredtool
+        mounts:
+          input.json: /data/in.json
+        alignImage: itextmine/align:2.0
+`)
+	configureError := pipelineConfig.ConfigureTools()
+	require.Equal(t, nil, configureError, configureError)
+
+	configuredTool, configuredToolError := tools.GetTool("configuredtool")
+	require.Equal(t, nil, configuredToolError, configuredToolError)
+	require.Equal(t, []string{"itextmine/configuredtool:1.2", "itextmine/align:2.0"}, configuredTool.Images())
+	require.Equal(t, []string{"^/medline-configuredtool-task", "^/configuredtool-align", "^/medline-db$"}, configuredTool.CleanupPatterns())
+
+	toolConfig, toolConfigError := tools.GetToolConfig("configuredtool")
+	require.Equal(t, nil, toolConfigError, toolConfigError)
+	require.Equal(t, tools.NetworkManifest{Name: "configuredtool", Subnet: "10.5.0.0/16"}, *toolConfig.Network)
+	require.Equal(t, tools.SidecarConfig{Image: "itextmine/configuredtool-db", IPAddress: "10.5.0.2"}, toolConfig.Sidecars["medline-db"])
+	require.Equal(t, map[string]string{"input.json": "/data/in.json", "output.json": "/workdir/out.json"}, toolConfig.Stages["configuredtool"].Mounts)
+
+	// unknown stages, sidecars and mounts are an error and leave the tool as it is
+	for _, toolConfig := range []tools.ToolConfig{
+		{Stages: map[string]tools.StageConfig{"unknown": {Image: "itextmine/unknown"}}},
+		{Sidecars: map[string]tools.SidecarConfig{"unknown": {Image: "itextmine/unknown"}}},
+		{Stages: map[string]tools.StageConfig{"configuredtool": {Mounts: map[string]string{"unknown.json": "/workdir/unknown.json"}}}},
+		{Stages: map[string]tools.StageConfig{"configuredtool": {Aligner: "python"}}},
+	} {
+		configureError = tools.ConfigureTool("configuredtool", toolConfig)
+		require.NotEqual(t, nil, configureError)
+	}
+	require.Equal(t, []string{"itextmine/configuredtool:1.2", "itextmine/align:2.0"}, configuredTool.Images())
+
+	// unknown tools
+	configureError = tools.ConfigureTool("unknown", tools.ToolConfig{})
+	require.NotEqual(t, nil, configureError)
+}
+
+// Test that the tag of a tool is added to its stage images only, the align image keeps its own version
+func TestConfigureToolTag(t *testing.T) {
+	manifest := tools.ToolManifest{
+		Name: "taggedtool",
+		Stages: []tools.StageManifest{
+			{
+				Name:  "taggedtool",
+				Image: "itextmine/taggedtool",
+				Mounts: []tools.MountManifest{
+					{Source: "input.json", Target: "/workdir/in.json", ReadOnly: true},
+					{Source: "output.json", Target: "/workdir/out.json", Create: true},
+				},
+				Output: "output.json",
+				Align:  &tools.AlignManifest{Output: "align.json"},
+			},
+		},
+	}
+	registerError := tools.RegisterTool(tools.NewManifestTool(manifest))
+	require.Equal(t, nil, registerError, registerError)
+
+	configureError := tools.ConfigureTool("taggedtool", tools.ToolConfig{Tag: "1.2"})
+	require.Equal(t, nil, configureError, configureError)
+
+	taggedTool, taggedToolError := tools.GetTool("taggedtool")
+	require.Equal(t, nil, taggedToolError, taggedToolError)
+	require.Equal(t, []string{"itextmine/taggedtool:1.2", "itextmine/align"}, taggedTool.Images())
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"itextmine/convert"
+	"itextmine/misc"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test converting PubMed XML to medline input docs
+func TestConvertMedline(t *testing.T) {
+	inputFile := "../data/convert/pubmed_sample.xml"
+	expectedFile := "../data/convert/pubmed_sample.json"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	expected, readError := ioutil.ReadFile(expectedFile)
+	require.Equal(t, nil, readError, readError)
+
+	converter, converterError := convert.GetConverter("medline")
+	require.Equal(t, nil, converterError, converterError)
+
+	// plain and compressed sources give the same docs
+	require.Equal(t, nil, os.MkdirAll(workDir, os.FileMode(0777)))
+	compressedInputFile := path.Join(workDir, "pubmed_sample.xml.gz")
+	compressFile(t, inputFile, compressedInputFile)
+
+	for _, sourceFile := range []string{inputFile, compressedInputFile} {
+		outputFile := path.Join(workDir, "medline.json")
+		recordCount, convertError := convert.ConvertFiles(converter, []string{sourceFile}, outputFile)
+		require.Equal(t, nil, convertError, convertError)
+		require.Equal(t, 4, recordCount)
+		require.Equal(t, string(expected), readFile(t, outputFile))
+	}
+
+	// the docs are valid pipeline input
+	for _, line := range strings.Split(strings.TrimSpace(string(expected)), "\n") {
+		require.Equal(t, nil, misc.ValidateDocument(line, "medline"))
+	}
+
+	// a citation without a title has no title offsets, its text starts with the abstract
+	records := strings.Split(strings.TrimSpace(string(expected)), "\n")
+	record := convert.MedlineRecord{}
+	require.Equal(t, nil, json.Unmarshal([]byte(records[3]), &record))
+	require.True(t, record.Title == nil)
+	require.Equal(t, 0, record.Sentence[0].CharStart)
+}
+
+// Test that an invalid source is reported
+func TestConvertMedlineInvalidXml(t *testing.T) {
+	output := bytes.Buffer{}
+	_, convertError := convert.ConvertMedline(strings.NewReader("<PubmedArticleSet><PubmedArticle>"), &output)
+	require.NotEqual(t, nil, convertError)
+
+	_, converterError := convert.GetConverter("genbank")
+	require.NotEqual(t, nil, converterError)
+}
+
+// pmcRecordsByDocId reads pmc section records by docId
+func pmcRecordsByDocId(t *testing.T, content string) map[string]convert.PmcRecord {
+	records := make(map[string]convert.PmcRecord)
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		record := convert.PmcRecord{}
+		require.Equal(t, nil, json.Unmarshal([]byte(line), &record))
+		records[record.DocId] = record
+	}
+	return records
+}
+
+// Test converting PMC JATS XML to pmc section records
+func TestConvertPmc(t *testing.T) {
+	inputFile := "../data/convert/pmc_sample.xml"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	// the expected records are records of the pmc collection, the sample holds their article
+	expectedFile := "../data/convert/pmc_sample.json"
+	expected, readError := ioutil.ReadFile(expectedFile)
+	require.Equal(t, nil, readError, readError)
+
+	converter, converterError := convert.GetConverter("pmc")
+	require.Equal(t, nil, converterError, converterError)
+
+	// the article without a pmcid is left out
+	outputFile := path.Join(workDir, "pmc.json")
+	recordCount, convertError := convert.ConvertFiles(converter, []string{inputFile}, outputFile)
+	require.Equal(t, nil, convertError, convertError)
+	require.Equal(t, 18, recordCount)
+
+	output := readFile(t, outputFile)
+	records := pmcRecordsByDocId(t, output)
+	for docId, expectedRecord := range pmcRecordsByDocId(t, string(expected)) {
+		require.Equal(t, expectedRecord, records[docId], docId)
+	}
+
+	// section titles end with a period like the titles of the collection
+	reference := pmcRecordsByDocId(t, readFile(t, "../data/mirtex/test_doc_in_pmc.json"))["PMC7017496-60"]
+	section := records["PMC5297868-5"]
+	require.Equal(t, reference.Text, section.Text)
+	require.Equal(t, reference.TitleOffset, section.TitleOffset)
+	require.Equal(t, reference.Sentence, section.Sentence)
+	require.Equal(t, reference.SecType, section.SecType)
+	require.Equal(t, "Introduction.", records["PMC5351198-1"].Text)
+	require.Equal(t, []int{0, 12}, records["PMC5351198-1"].TitleOffset)
+
+	// the records are valid pipeline input and their parents are sections of the same article
+	sectionIds := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		require.Equal(t, nil, misc.ValidateDocument(line, misc.COLLECTION_PMC))
+
+		record := convert.PmcRecord{}
+		require.Equal(t, nil, json.Unmarshal([]byte(line), &record))
+		require.Equal(t, fmt.Sprintf("%s-%d", record.Pmcid, record.Id), record.DocId)
+		for _, parent := range record.Parent {
+			require.True(t, sectionIds[fmt.Sprintf("%s-%d", record.Pmcid, parent)], "%s has an unknown parent %d", record.DocId, parent)
+		}
+
+		if record.Type == convert.PMC_TYPE_SECTION {
+			sectionIds[record.DocId] = true
+		}
+	}
+}
+
+// Test splitting abstracts into sentences
+func TestSplitSentences(t *testing.T) {
+	text := "Levels were measured e.g. in serum (Fig. 2). Dr. Smith et al. found a 2.5-fold increase. J. Doe agreed! Was it 3? 4 patients."
+	sentences := convert.SplitSentences(text)
+
+	sentenceTexts := make([]string, 0, len(sentences))
+	for _, sentence := range sentences {
+		sentenceTexts = append(sentenceTexts, string([]rune(text)[sentence.CharStart:sentence.CharEnd+1]))
+	}
+
+	require.Equal(t, []string{
+		"Levels were measured e.g. in serum (Fig. 2).",
+		"Dr. Smith et al. found a 2.5-fold increase.",
+		"J. Doe agreed!",
+		"Was it 3?",
+		"4 patients.",
+	}, sentenceTexts)
+}
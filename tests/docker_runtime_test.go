@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"context"
+	"itextmine/misc"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that only the tag of the image is pulled, from the registry of the image
+func TestDockerImagePull(t *testing.T) {
+	pulledImages := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		pulledImages = append(pulledImages, request.URL.Query().Get("fromImage")+" "+request.URL.Query().Get("tag"))
+		writer.Write([]byte("{\"status\": \"Downloaded\"}\n"))
+	}))
+	defer server.Close()
+
+	dockerClient, clientError := client.NewClient(strings.Replace(server.URL, "http://", "tcp://", 1), client.DefaultVersion, nil, nil)
+	require.Equal(t, nil, clientError, clientError)
+	dockerRuntime := misc.NewDockerRuntime(dockerClient)
+
+	for _, imageName := range []string{"itextmine/rlimsp", "itextmine/rlimsp:1.2", "ghcr.io/itextmine/align:2.0", "localhost:5000/itextmine/mirtex"} {
+		pullError := dockerRuntime.ImagePull(context.Background(), imageName)
+		require.Equal(t, nil, pullError, pullError)
+	}
+	require.Equal(t, []string{
+		"docker.io/itextmine/rlimsp latest",
+		"docker.io/itextmine/rlimsp 1.2",
+		"ghcr.io/itextmine/align 2.0",
+		"localhost:5000/itextmine/mirtex latest",
+	}, pulledImages)
+
+	pullError := dockerRuntime.ImagePull(context.Background(), "Invalid Image")
+	require.NotEqual(t, nil, pullError)
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"itextmine/misc"
+	"itextmine/tools"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that planning a split counts the tasks the split creates, without writing them
+func TestPlanSplit(t *testing.T) {
+	inputDoc := "../data/rlimsp/test_split_doc_in.json"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	for _, splitOptions := range []misc.SplitOptions{
+		{LinesPerTask: 100},
+		{LinesPerTask: 7},
+		{CharsPerTask: 5000},
+		{LinesPerTask: 100, Validate: true, CollectionType: "medline"},
+	} {
+		counts, planError := misc.PlanSplit([]string{inputDoc}, splitOptions)
+		require.Equal(t, nil, planError, planError)
+		require.Equal(t, false, misc.TaskFoldersExist(workDir, "rlimsp"))
+
+		// the split creates as many task folders
+		splitError := misc.SplitInputDocs([]string{inputDoc}, workDir, "rlimsp", splitOptions)
+		require.Equal(t, nil, splitError, splitError)
+
+		taskDirNames, taskDirNamesError := misc.GetSubDirNames(path.Join(workDir, "rlimsp"))
+		require.Equal(t, nil, taskDirNamesError, taskDirNamesError)
+		require.Equal(t, len(*taskDirNames), counts.Tasks)
+
+		lineCount, lineCountError := CountLines(inputDoc)
+		require.Equal(t, nil, lineCountError, lineCountError)
+		require.Equal(t, lineCount, counts.Documents)
+
+		cleanError := misc.CleanDir(workDir)
+		require.Equal(t, nil, cleanError, cleanError)
+	}
+}
+
+// Test the images, networks and containers of a planned run
+func TestPlanRun(t *testing.T) {
+	plan, planError := tools.PlanRun("rlimsp", "", "", 3)
+	require.Equal(t, nil, planError, planError)
+	require.Equal(t, []string{"itextmine/rlimsp", "leebird/efip", "itextmine/align", "itextmine/rlimsp-mysql"}, plan.Images)
+	require.Equal(t, []string{"rlimsp"}, plan.Networks)
+	require.Equal(t, []string{"rlimsp-mysql"}, plan.Sidecars)
+	require.Equal(t, 4, len(plan.Containers))
+	require.Equal(t, tools.ContainerPlan{Stage: "efip", Image: "leebird/efip", First: "rlimsp-efip-task_0", Last: "rlimsp-efip-task_2", Count: 3}, plan.Containers[2])
+
+	// the names of a run with an id
+	plan, planError = tools.PlanRun("rlimsp", "", "run1", 3)
+	require.Equal(t, nil, planError, planError)
+	require.Equal(t, []string{"run1-rlimsp"}, plan.Networks)
+	require.Equal(t, []string{"run1-rlimsp-mysql"}, plan.Sidecars)
+	require.Equal(t, "run1-efip-align-task_2", plan.Containers[3].Last)
+
+	// native alignment needs no align containers
+	plan, planError = tools.PlanRun("mirtex", tools.ALIGNER_NATIVE, "", 10)
+	require.Equal(t, nil, planError, planError)
+	require.Equal(t, []string{"itextmine/mirtex"}, plan.Images)
+	require.Equal(t, []tools.ContainerPlan{{Stage: "mirtex", Image: "itextmine/mirtex", First: "mirtex-task_0", Last: "mirtex-task_9", Count: 10}}, plan.Containers)
+
+	// the reduced files
+	mirtexTool, mirtexToolError := tools.GetTool("mirtex")
+	require.Equal(t, nil, mirtexToolError, mirtexToolError)
+	require.Equal(t, "output_dir/mirtex.medline.align.json.zst", tools.ReduceOutputPath("output_dir", "medline", mirtexTool.ReduceOutputs()[1], tools.ReduceOptions{Compression: "zst"}))
+
+	_, planError = tools.PlanRun("unknown", "", "", 1)
+	require.NotEqual(t, nil, planError)
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"itextmine/misc"
+	"itextmine/tools"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that a crashing tool fails its task with the tail of its logs, and that the logs are kept per task
+func TestExecuteExitCode(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+
+	defer misc.CleanDir(workDir)
+
+	// split the document
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// task_3 crashes after logging a stack trace
+	mirtexRunner := FakeToolRunner("/mirtex_workdir/in.json", "/mirtex_workdir/out.json")
+
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+	fakeRuntime.SetRunner("itextmine/mirtex", func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		if spec.Name == "mirtex-task_3" {
+			for lineIndex := 0; lineIndex < 30; lineIndex++ {
+				fmt.Fprintf(logs, "at line %d\n", lineIndex)
+			}
+			fmt.Fprintln(logs, "java.lang.OutOfMemoryError: Java heap space")
+			return 137, nil
+		}
+		return mirtexRunner(spec, mounts, logs)
+	})
+
+	executeError := tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2})
+	require.NotEqual(t, nil, executeError)
+	require.Contains(t, executeError.Error(), "1 tasks failed: task_3")
+	require.Contains(t, executeError.Error(), "exited with code 137")
+	require.Contains(t, executeError.Error(), "java.lang.OutOfMemoryError: Java heap space")
+
+	// only the tail of the logs is part of the error
+	require.Contains(t, executeError.Error(), "at line 29")
+	require.NotContains(t, executeError.Error(), "at line 5\n")
+
+	// the logs are kept per task and stage
+	crashLog, crashLogError := ioutil.ReadFile(workDir + "/mirtex/task_3/mirtex.log")
+	require.Equal(t, nil, crashLogError, crashLogError)
+	require.Contains(t, string(crashLog), "at line 0\n")
+
+	toolLog, toolLogError := ioutil.ReadFile(workDir + "/mirtex/task_1/mirtex.log")
+	require.Equal(t, nil, toolLogError, toolLogError)
+	require.Equal(t, "itextmine/mirtex processed 20 documents\n", string(toolLog))
+
+	alignLogExists, _ := misc.PathExists(workDir + "/mirtex/task_1/mirtex-align.log")
+	require.True(t, alignLogExists)
+
+	// the crashed task did not get aligned
+	checkpoint, checkpointError := tools.LoadCheckpoint(tools.CheckpointPath(workDir, "mirtex"), "mirtex")
+	require.Equal(t, nil, checkpointError, checkpointError)
+	require.Equal(t, tools.STAGE_FAILED, checkpoint.Tasks["task_3"]["mirtex"])
+	require.Equal(t, "", checkpoint.Tasks["task_3"]["mirtex-align"])
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"itextmine/misc"
+	"itextmine/tools"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test split, execute, align and reduce of mirtex without docker
+func TestExecuteMirtexFakeRuntime(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	// script the containers
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/mirtex", FakeToolRunner("/mirtex_workdir/in.json", "/mirtex_workdir/out.json"))
+	fakeRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+
+	// split the document
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// Execute mirtex
+	executeError := tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 3})
+	require.Equal(t, nil, executeError, executeError)
+	require.Equal(t, []string{"itextmine/mirtex", "itextmine/align"}, fakeRuntime.PulledImages)
+	require.Equal(t, 10, len(fakeRuntime.CreatedContainers))
+	require.Equal(t, []string{}, fakeRuntime.ContainerNames())
+
+	// Reduce
+	reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "medline", tools.ReduceOptions{})
+	require.Equal(t, nil, reduceError, reduceError)
+
+	outputLineCount, outputLineCountError := CountLines(path.Join(outPutDir, "mirtex.medline.output.json"))
+	require.Equal(t, nil, outputLineCountError, outputLineCountError)
+	require.Equal(t, 100, outputLineCount)
+
+	alignLineCount, alignLineCountError := CountLines(path.Join(outPutDir, "mirtex.medline.align.json"))
+	require.Equal(t, nil, alignLineCountError, alignLineCountError)
+	require.Equal(t, 100, alignLineCount)
+}
+
+// Test that the rlimsp network and mysql sidecar are created and removed
+func TestExecuteRlimspFakeRuntime(t *testing.T) {
+	inputDoc := "../data/rlimsp/test_execute_doc_in.json"
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	// script the containers, efip reads the text output of rlimsp
+	NoSidecarDelay(t, "rlimsp")
+	fakeRuntime := FakeRlimspRuntime()
+
+	// split the document
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "rlimsp", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// Execute rlimsp
+	executeError := tools.Execute(fakeRuntime, "rlimsp", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 3})
+	require.Equal(t, nil, executeError, executeError)
+	require.Equal(t, []string{"rlimsp"}, fakeRuntime.CreatedNetworks)
+
+	// the sidecar, the align containers and the network are gone
+	require.Equal(t, 0, len(fakeRuntime.NetworkNames()))
+	require.Equal(t, []string{}, fakeRuntime.ContainerNames())
+
+	// Reduce
+	reduceError := tools.Reduce(workDir, outPutDir, "rlimsp", "medline", tools.ReduceOptions{})
+	require.Equal(t, nil, reduceError, reduceError)
+
+	for _, reducedFile := range []string{"rlimsp.medline.output.json", "rlimsp.medline.align.json", "efip.medline.output.json", "efip.medline.align.json"} {
+		lineCount, lineCountError := CountLines(path.Join(outPutDir, reducedFile))
+		require.Equal(t, nil, lineCountError, lineCountError)
+		require.Equal(t, 80, lineCount, reducedFile)
+	}
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"itextmine/misc"
+	"itextmine/tools"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that an interrupted run removes its containers, sidecar and network and can be resumed
+func TestExecuteInterrupted(t *testing.T) {
+	inputDoc := "../data/rlimsp/test_execute_doc_in.json"
+	workDir := "test_workdir"
+
+	defer misc.CleanDir(workDir)
+
+	// split the document
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "rlimsp", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// the run is interrupted while efip runs on task_1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	NoSidecarDelay(t, "rlimsp")
+	efipRunner := FakeToolRunner("/efip_workdir/docs.rlims.txt", "/efip_workdir/docs.json")
+	fakeRuntime := FakeRlimspRuntime()
+	fakeRuntime.SetRunner("leebird/efip", func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		if spec.Name == "rlimsp-efip-task_1" {
+			cancel()
+			time.Sleep(time.Second)
+			return 0, nil
+		}
+		return efipRunner(spec, mounts, logs)
+	})
+
+	executeError := tools.Execute(fakeRuntime, "rlimsp", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 1, Context: ctx})
+	require.NotEqual(t, nil, executeError)
+	require.True(t, tools.IsInterruptedError(executeError), executeError.Error())
+
+	// the interrupted container, the sidecar and the network are gone
+	require.NotContains(t, fakeRuntime.ContainerNames(), "rlimsp-efip-task_1")
+	require.NotContains(t, fakeRuntime.ContainerNames(), "rlimsp-mysql")
+	require.Equal(t, 0, len(fakeRuntime.NetworkNames()))
+	for _, containerName := range fakeRuntime.ContainerNames() {
+		require.NotContains(t, containerName, "-align-task_")
+	}
+
+	// the interrupted stage and the tasks that were not started are pending
+	checkpoint, checkpointError := tools.LoadCheckpoint(tools.CheckpointPath(workDir, "rlimsp"), "rlimsp")
+	require.Equal(t, nil, checkpointError, checkpointError)
+	require.Equal(t, tools.STAGE_DONE, checkpoint.Tasks["task_0"]["efip-align"])
+	require.Equal(t, tools.STAGE_DONE, checkpoint.Tasks["task_1"]["rlimsp-align"])
+	require.Equal(t, "", checkpoint.Tasks["task_1"]["efip"])
+	require.Equal(t, 0, len(checkpoint.Tasks["task_3"]))
+
+	// the resumed run executes the rest
+	fakeRuntime.SetRunner("leebird/efip", efipRunner)
+	executeError = tools.Execute(fakeRuntime, "rlimsp", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2, Resume: true})
+	require.Equal(t, nil, executeError, executeError)
+
+	status, statusError := tools.Status(workDir, "rlimsp")
+	require.Equal(t, nil, statusError, statusError)
+	require.True(t, status.Done(), status.String())
+}
+
+// Test that a run interrupted while its sidecar starts up does not wait out the startup delay
+func TestExecuteInterruptedSetup(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	manifest := tools.ToolManifest{
+		Name:     "slowsidecartool",
+		Network:  &tools.NetworkManifest{Name: "slowsidecartool"},
+		Sidecars: []tools.SidecarManifest{{Name: "slowsidecartool-db", Image: "itextmine/slowsidecartool-db", StartupDelay: "1m"}},
+		Stages: []tools.StageManifest{
+			{
+				Name:   "slowsidecartool",
+				Image:  "itextmine/slowsidecartool",
+				Mounts: []tools.MountManifest{{Source: "input.json", Target: "/workdir/in.json", ReadOnly: true}},
+			},
+		},
+	}
+	registerError := tools.RegisterTool(tools.NewManifestTool(manifest))
+	require.Equal(t, nil, registerError, registerError)
+
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "slowsidecartool", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	fakeRuntime := misc.NewFakeRuntime()
+	startTime := time.Now()
+	executeError := tools.Execute(fakeRuntime, "slowsidecartool", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 1, Context: ctx})
+	require.True(t, tools.IsInterruptedError(executeError), executeError)
+	require.True(t, time.Since(startTime) < 10*time.Second, time.Since(startTime).String())
+
+	// the sidecar and the network are gone
+	require.Equal(t, []string{}, fakeRuntime.ContainerNames())
+	require.Equal(t, 0, len(fakeRuntime.NetworkNames()))
+}
+
+// Test that the tasks that failed before the run was interrupted are still reported
+func TestExecuteInterruptedFailures(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// mirtex crashes on task_0, the run is interrupted while it runs on task_1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mirtexRunner := FakeToolRunner("/mirtex_workdir/in.json", "/mirtex_workdir/out.json")
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+	fakeRuntime.SetRunner("itextmine/mirtex", func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		if spec.Name == "mirtex-task_0" {
+			io.WriteString(logs, "mirtex crashed\n")
+			return 1, nil
+		} else if spec.Name == "mirtex-task_1" {
+			cancel()
+			time.Sleep(time.Second)
+			return 0, nil
+		}
+		return mirtexRunner(spec, mounts, logs)
+	})
+
+	executeError := tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 1, Context: ctx})
+	require.True(t, tools.IsInterruptedError(executeError), executeError)
+
+	interruptedError := executeError.(*tools.InterruptedError)
+	require.NotNil(t, interruptedError.Failures)
+	require.Equal(t, []string{"task_0"}, interruptedError.Failures.FailedTasks())
+	require.Contains(t, executeError.Error(), "interrupted after 1 tasks failed")
+}
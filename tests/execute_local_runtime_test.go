@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"context"
+	"itextmine/misc"
+	"itextmine/tools"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test running mirtex and align as local processes
+func TestExecuteMirtexLocalRuntime(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	// copy the input as tool output and the tool output as aligned output
+	localRuntime := misc.NewLocalRuntime(misc.LocalCommands{
+		Commands: map[string][]string{
+			"itextmine/mirtex": {"cp", "{/mirtex_workdir/in.json}", "{/mirtex_workdir/out.json}"},
+			"itextmine/align":  {"cp", "{/align_workdir/result_file.json}", "{/align_workdir/output_file.json}"},
+		},
+	})
+
+	// split the document
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 30)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// Execute mirtex
+	executeError := tools.Execute(localRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2})
+	require.Equal(t, nil, executeError, executeError)
+
+	// Reduce
+	reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "medline", tools.ReduceOptions{})
+	require.Equal(t, nil, reduceError, reduceError)
+
+	alignLineCount, alignLineCountError := CountLines(path.Join(outPutDir, "mirtex.medline.align.json"))
+	require.Equal(t, nil, alignLineCountError, alignLineCountError)
+	require.Equal(t, 100, alignLineCount)
+}
+
+// Test that images without a local command are rejected before the tasks start
+func TestLocalRuntimeMissingCommand(t *testing.T) {
+	localRuntime, runtimeError := misc.CreateLocalRuntime("../data/local/commands.yaml")
+	require.Equal(t, nil, runtimeError, runtimeError)
+
+	pullError := localRuntime.ImagePull(context.Background(), "itextmine/unknown")
+	require.NotEqual(t, nil, pullError)
+}
@@ -29,7 +29,7 @@ func TestExcuteMirtex(t *testing.T) {
 	require.Equal(t, nil, rlimspError, rlimspError)
 
 	// Reduce
-	reduceError := tools.Reduce(workDir, outPutDir, toolName, collectionType)
+	reduceError := tools.Reduce(workDir, outPutDir, toolName, collectionType, tools.ReduceOptions{})
 	require.Equal(t, nil, reduceError, reduceError)
 
 }
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"itextmine/misc"
+	"itextmine/tools"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that a resumed run only executes the stages that did not complete
+func TestExecuteResume(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	// split the document
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// the first run fails on task_1
+	mirtexRunner := FakeToolRunner("/mirtex_workdir/in.json", "/mirtex_workdir/out.json")
+	failingRuntime := misc.NewFakeRuntime()
+	failingRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+	failingRuntime.SetRunner("itextmine/mirtex", func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		if spec.Name == "mirtex-task_1" {
+			return -1, errors.New("daemon went away")
+		}
+		return mirtexRunner(spec, mounts, logs)
+	})
+
+	executeError := tools.Execute(failingRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2})
+	require.NotEqual(t, nil, executeError)
+
+	checkpoint, checkpointError := tools.LoadCheckpoint(tools.CheckpointPath(workDir, "mirtex"), "mirtex")
+	require.Equal(t, nil, checkpointError, checkpointError)
+	require.Equal(t, tools.STAGE_FAILED, checkpoint.Tasks["task_1"]["mirtex"])
+	require.True(t, checkpoint.IsDone("task_0", "mirtex-align"))
+
+	// the resumed run only executes task_1
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/mirtex", mirtexRunner)
+	fakeRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+
+	resumeError := tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2, Resume: true})
+	require.Equal(t, nil, resumeError, resumeError)
+	require.Equal(t, 2, len(fakeRuntime.CreatedContainers))
+	require.Equal(t, "mirtex-task_1", fakeRuntime.CreatedContainers[0].Name)
+
+	// Reduce
+	reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "medline", tools.ReduceOptions{})
+	require.Equal(t, nil, reduceError, reduceError)
+
+	alignLineCount, alignLineCountError := CountLines(path.Join(outPutDir, "mirtex.medline.align.json"))
+	require.Equal(t, nil, alignLineCountError, alignLineCountError)
+	require.Equal(t, 100, alignLineCount)
+}
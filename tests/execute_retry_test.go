@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"itextmine/misc"
+	"itextmine/tools"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that transient docker errors are retried and tool failures are not
+func TestExecuteRetries(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+
+	defer misc.CleanDir(workDir)
+
+	// split the document
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// task_1 loses the daemon once, task_3 always crashes
+	attemptsMutex := sync.Mutex{}
+	attempts := make(map[string]int)
+	mirtexRunner := FakeToolRunner("/mirtex_workdir/in.json", "/mirtex_workdir/out.json")
+
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+	fakeRuntime.SetRunner("itextmine/mirtex", func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		attemptsMutex.Lock()
+		attempts[spec.Name] = attempts[spec.Name] + 1
+		attempt := attempts[spec.Name]
+		attemptsMutex.Unlock()
+
+		if spec.Name == "mirtex-task_1" && attempt == 1 {
+			return -1, errors.New("Cannot connect to the Docker daemon at unix:///var/run/docker.sock")
+		} else if spec.Name == "mirtex-task_3" {
+			return -1, errors.New("mirtex crashed")
+		}
+		return mirtexRunner(spec, mounts, logs)
+	})
+
+	retryPolicy := tools.RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	executeError := tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2, RetryPolicy: retryPolicy})
+	require.NotEqual(t, nil, executeError)
+	require.Contains(t, executeError.Error(), "1 tasks failed: task_3")
+
+	require.Equal(t, 2, attempts["mirtex-task_1"])
+	require.Equal(t, 1, attempts["mirtex-task_3"])
+}
+
+// Test the exponential backoff and the per stage retries
+func TestRetryPolicy(t *testing.T) {
+	retryPolicy := tools.RetryPolicy{
+		MaxRetries:     2,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		StageRetries:   map[string]int{"efip": 4, "align": 1},
+	}
+
+	require.Equal(t, time.Second, retryPolicy.Backoff(0))
+	require.Equal(t, 4*time.Second, retryPolicy.Backoff(2))
+	require.Equal(t, 5*time.Second, retryPolicy.Backoff(3))
+
+	require.Equal(t, 2, retryPolicy.Retries("rlimsp"))
+	require.Equal(t, 4, retryPolicy.Retries("efip"))
+	require.Equal(t, 1, retryPolicy.Retries("efip-align"))
+
+	require.True(t, tools.IsTransientError(misc.WrapRuntimeError(errors.New("Conflict. The container name \"/mirtex-task_1\" is already in use"))))
+	require.False(t, tools.IsTransientError(misc.WrapRuntimeError(errors.New("mirtex crashed"))))
+
+	// only the errors of the container runtime are retried, not the ones of the native aligner
+	require.False(t, tools.IsTransientError(errors.New("test_workdir/mirtex/task_1/output.json:3: unexpected EOF")))
+	require.True(t, tools.IsTransientError(misc.WrapRuntimeError(errors.New("read unix @->/var/run/docker.sock: unexpected EOF"))))
+}
@@ -27,7 +27,7 @@ func TestExcuteRlimsp(t *testing.T) {
 	require.Equal(t, nil, rlimspError, rlimspError)
 
 	// Reduce
-	reduceError := tools.Reduce(workDir, outPutDir, "rlimsp", "medline")
+	reduceError := tools.Reduce(workDir, outPutDir, "rlimsp", "medline", tools.ReduceOptions{})
 	require.Equal(t, nil, reduceError, reduceError)
 
 }
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"itextmine/constants"
+	"itextmine/misc"
+	"itextmine/tools"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that two runs of a tool on one host name and label their containers after the run and only clean up their own
+func TestExecuteRunID(t *testing.T) {
+	inputDoc := "../data/rlimsp/test_execute_doc_in.json"
+	workDirs := map[string]string{"run1": "test_workdir", "run2": "test_workdir2"}
+
+	NoSidecarDelay(t, "rlimsp")
+	fakeRuntime := FakeRlimspRuntime()
+
+	for runID, workDir := range workDirs {
+		defer misc.CleanDir(workDir)
+
+		splitErr := misc.SplitInputDoc(inputDoc, workDir, "rlimsp", 20)
+		require.Equal(t, nil, splitErr, splitErr)
+
+		executeError := tools.Execute(fakeRuntime, "rlimsp", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2, RunID: runID})
+		require.Equal(t, nil, executeError, executeError)
+	}
+
+	// the containers and the network are named and labeled after the run
+	require.Contains(t, fakeRuntime.CreatedNetworks, "run1-rlimsp")
+	require.Contains(t, fakeRuntime.CreatedNetworks, "run2-rlimsp")
+	for _, containerSpec := range fakeRuntime.CreatedContainers {
+		runID := containerSpec.Labels[constants.LABEL_RUN_ID]
+		require.Contains(t, workDirs, runID)
+		require.True(t, strings.HasPrefix(containerSpec.Name, runID+"-"), containerSpec.Name)
+		require.Equal(t, "rlimsp", containerSpec.Labels[constants.LABEL_TOOL])
+	}
+	efipLabels := make(map[string]string)
+	for _, containerSpec := range fakeRuntime.CreatedContainers {
+		if containerSpec.Name == "run1-rlimsp-efip-task_1" {
+			efipLabels = containerSpec.Labels
+		}
+	}
+	require.Equal(t, map[string]string{constants.LABEL_RUN_ID: "run1", constants.LABEL_TOOL: "rlimsp", constants.LABEL_TASK: "task_1", constants.LABEL_STAGE: "efip"}, efipLabels)
+
+	// without RunSubnet the sidecar keeps the address of the manifest
+	for _, containerSpec := range fakeRuntime.CreatedContainers {
+		if strings.HasSuffix(containerSpec.Name, "-rlimsp-mysql") {
+			require.Equal(t, "10.0.0.2", containerSpec.IPAddress)
+		}
+	}
+
+	// cleaning up without a run id keeps the containers of the runs
+	runContainerNames := fakeRuntime.ContainerNames()
+	require.NotEqual(t, 0, len(runContainerNames))
+	cleanError := tools.Clean(fakeRuntime, "rlimsp", "")
+	require.Equal(t, nil, cleanError, cleanError)
+	require.Equal(t, runContainerNames, fakeRuntime.ContainerNames())
+
+	// cleaning one run keeps the containers of the other
+	cleanError = tools.Clean(fakeRuntime, "rlimsp", "run1")
+	require.Equal(t, nil, cleanError, cleanError)
+	for _, containerName := range fakeRuntime.ContainerNames() {
+		require.True(t, strings.HasPrefix(containerName, "run2-"), containerName)
+	}
+
+	cleanError = tools.Clean(fakeRuntime, "rlimsp", "run2")
+	require.Equal(t, nil, cleanError, cleanError)
+	require.Equal(t, []string{}, fakeRuntime.ContainerNames())
+
+	// run ids must be valid in container names
+	executeError := tools.Execute(fakeRuntime, "rlimsp", tools.ExecuteOptions{WorkDir: "test_workdir", NumParallelTasks: 2, RunID: "run/1"})
+	require.NotEqual(t, nil, executeError)
+}
+
+// Test that two runs of a tool with a network overlap in time, each on a subnet of its own
+func TestConcurrentRuns(t *testing.T) {
+	inputDoc := "../data/rlimsp/test_execute_doc_in.json"
+	workDirs := map[string]string{"run1": "test_workdir", "run2": "test_workdir2"}
+
+	// the first rlimsp container of each run waits for the one of the other run
+	runsStarted := sync.WaitGroup{}
+	runsStarted.Add(len(workDirs))
+	allStarted := make(chan bool)
+	go func() {
+		runsStarted.Wait()
+		close(allStarted)
+	}()
+
+	NoSidecarDelay(t, "rlimsp")
+	fakeRuntime := FakeRlimspRuntime()
+	fakeRuntime.SetRunner("itextmine/rlimsp", func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		if strings.HasSuffix(spec.Name, "-rlimsp-task_0") {
+			runsStarted.Done()
+			select {
+			case <-allStarted:
+			case <-time.After(10 * time.Second):
+				return 1, errors.New("the other run did not start")
+			}
+		}
+		return FakeRlimspRunner()(spec, mounts, logs)
+	})
+
+	executeErrors := make(chan error, len(workDirs))
+	for runID, workDir := range workDirs {
+		defer misc.CleanDir(workDir)
+
+		splitErr := misc.SplitInputDoc(inputDoc, workDir, "rlimsp", 20)
+		require.Equal(t, nil, splitErr, splitErr)
+
+		go func(runID string, workDir string) {
+			executeErrors <- tools.Execute(fakeRuntime, "rlimsp", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 1, RunID: runID, RunSubnet: true})
+		}(runID, workDir)
+	}
+
+	// the fake runtime refuses overlapping subnets like docker does
+	for range workDirs {
+		executeError := <-executeErrors
+		require.Equal(t, nil, executeError, executeError)
+	}
+
+	// the sidecars have an address in the subnet of their run and keep their name on the network
+	sidecarAddresses := make(map[string]string)
+	for _, containerSpec := range fakeRuntime.CreatedContainers {
+		if strings.HasSuffix(containerSpec.Name, "-rlimsp-mysql") {
+			sidecarAddresses[containerSpec.Name] = containerSpec.IPAddress
+			require.Equal(t, []string{"rlimsp-mysql"}, containerSpec.Aliases)
+		}
+	}
+	require.Equal(t, 2, len(sidecarAddresses))
+	require.NotEqual(t, sidecarAddresses["run1-rlimsp-mysql"], sidecarAddresses["run2-rlimsp-mysql"])
+	for _, sidecarAddress := range sidecarAddresses {
+		require.True(t, strings.HasPrefix(sidecarAddress, "10.") && strings.HasSuffix(sidecarAddress, ".0.2"), sidecarAddress)
+	}
+}
+
+// Test that a run fails before any container starts when its subnet is taken
+func TestSubnetInUse(t *testing.T) {
+	inputDoc := "../data/rlimsp/test_execute_doc_in.json"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "rlimsp", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	fakeRuntime := misc.NewFakeRuntime()
+	_, networkCreateError := fakeRuntime.NetworkCreate(context.Background(), "other", "10.0.0.0/8", nil)
+	require.Equal(t, nil, networkCreateError, networkCreateError)
+
+	executeError := tools.Execute(fakeRuntime, "rlimsp", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2, RunID: "run1", RunSubnet: true})
+	require.NotEqual(t, nil, executeError)
+	require.Contains(t, executeError.Error(), "is in use by another network")
+	require.Equal(t, 0, len(fakeRuntime.CreatedContainers))
+}
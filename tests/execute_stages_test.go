@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"io"
+	"itextmine/misc"
+	"itextmine/tools"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test running the align stages again on a workdir, and the status and cleanup of the run
+func TestExecuteAlignStages(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+
+	defer misc.CleanDir(workDir)
+
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// nothing ran yet
+	status, statusError := tools.Status(workDir, "mirtex")
+	require.Equal(t, nil, statusError, statusError)
+	require.Equal(t, 5, status.Tasks)
+	require.Equal(t, 5, status.Stages[0].Statuses[tools.STAGE_PENDING])
+	require.False(t, status.Done())
+
+	// task_2 crashes
+	mirtexRunner := FakeToolRunner("/mirtex_workdir/in.json", "/mirtex_workdir/out.json")
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+	fakeRuntime.SetRunner("itextmine/mirtex", func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		if spec.Name == "mirtex-task_2" {
+			return 1, nil
+		}
+		return mirtexRunner(spec, mounts, logs)
+	})
+
+	executeError := tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2})
+	require.NotEqual(t, nil, executeError)
+
+	status, statusError = tools.Status(workDir, "mirtex")
+	require.Equal(t, nil, statusError, statusError)
+	require.Equal(t, map[string]int{tools.STAGE_DONE: 4, tools.STAGE_FAILED: 1}, status.Stages[0].Statuses)
+	require.Equal(t, map[string]int{tools.STAGE_DONE: 4, tools.STAGE_PENDING: 1}, status.Stages[1].Statuses)
+	require.Equal(t, []string{"task_2"}, status.FailedTasks)
+
+	// only the align stages run again, the task without a tool output is left alone
+	alignRuntime := misc.NewFakeRuntime()
+	alignRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+	executeError = tools.Execute(alignRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2, Stages: []string{"mirtex-align"}})
+	require.Equal(t, nil, executeError, executeError)
+	require.Equal(t, 4, len(alignRuntime.CreatedContainers))
+	for _, containerSpec := range alignRuntime.CreatedContainers {
+		require.Equal(t, "itextmine/align", containerSpec.Image)
+		require.NotEqual(t, "mirtex-align-task_2", containerSpec.Name)
+	}
+
+	// unknown stages are rejected
+	executeError = tools.Execute(alignRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 2, Stages: []string{"efip"}})
+	require.NotEqual(t, nil, executeError)
+
+	// the align containers are removed by the run
+	require.Equal(t, []string{}, alignRuntime.ContainerNames())
+	cleanError := tools.Clean(alignRuntime, "mirtex", "")
+	require.Equal(t, nil, cleanError, cleanError)
+	require.Equal(t, []string{}, alignRuntime.ContainerNames())
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"io"
+	"itextmine/misc"
+	"itextmine/tools"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that a hanging stage is stopped and recorded as timed out while the other tasks complete
+func TestExecuteStageTimeout(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+
+	defer misc.CleanDir(workDir)
+
+	// split the document
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// task_2 hangs
+	mirtexRunner := FakeToolRunner("/mirtex_workdir/in.json", "/mirtex_workdir/out.json")
+
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+	fakeRuntime.SetRunner("itextmine/mirtex", func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		if spec.Name == "mirtex-task_2" {
+			time.Sleep(2 * time.Second)
+			return 0, nil
+		}
+		return mirtexRunner(spec, mounts, logs)
+	})
+
+	stageTimeouts := tools.StageTimeouts{Default: 500 * time.Millisecond}
+	executeError := tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 5, StageTimeouts: stageTimeouts})
+	require.NotEqual(t, nil, executeError)
+	require.Contains(t, executeError.Error(), "1 tasks failed: task_2")
+	require.Contains(t, executeError.Error(), "timed out after 500ms")
+
+	// the hanging container was removed
+	require.NotContains(t, fakeRuntime.ContainerNames(), "mirtex-task_2")
+
+	checkpoint, checkpointError := tools.LoadCheckpoint(tools.CheckpointPath(workDir, "mirtex"), "mirtex")
+	require.Equal(t, nil, checkpointError, checkpointError)
+	require.Equal(t, tools.STAGE_TIMED_OUT, checkpoint.Tasks["task_2"]["mirtex"])
+	require.Equal(t, tools.STAGE_DONE, checkpoint.Tasks["task_1"]["mirtex-align"])
+	require.Equal(t, tools.STAGE_DONE, checkpoint.Tasks["task_4"]["mirtex-align"])
+}
+
+// Test the per stage timeouts
+func TestStageTimeouts(t *testing.T) {
+	stageTimeouts := tools.StageTimeouts{
+		Default: time.Hour,
+		Stages:  map[string]time.Duration{"rlimsp": 2 * time.Hour, "align": time.Minute},
+	}
+
+	require.Equal(t, 2*time.Hour, stageTimeouts.Timeout("rlimsp"))
+	require.Equal(t, time.Hour, stageTimeouts.Timeout("efip"))
+	require.Equal(t, time.Minute, stageTimeouts.Timeout("efip-align"))
+}
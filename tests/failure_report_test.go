@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"itextmine/misc"
+	"itextmine/tools"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that every failed task is collected and reported
+func TestFailureReport(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	// split the document
+	splitErr := misc.SplitInputDoc(inputDoc, workDir, "mirtex", 20)
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// task_1 crashes, the container of task_4 cannot be started
+	mirtexRunner := FakeToolRunner("/mirtex_workdir/in.json", "/mirtex_workdir/out.json")
+
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+	fakeRuntime.SetRunner("itextmine/mirtex", func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		if spec.Name == "mirtex-task_1" {
+			fmt.Fprintln(logs, "Segmentation fault")
+			return 139, nil
+		} else if spec.Name == "mirtex-task_4" {
+			return -1, errors.New("mirtex image is corrupt")
+		}
+		return mirtexRunner(spec, mounts, logs)
+	})
+
+	executeError := tools.Execute(fakeRuntime, "mirtex", tools.ExecuteOptions{WorkDir: workDir, NumParallelTasks: 3})
+	require.NotEqual(t, nil, executeError)
+	require.Contains(t, executeError.Error(), "2 tasks failed: task_1, task_4")
+
+	failures, isExecuteError := executeError.(*tools.ExecuteError)
+	require.True(t, isExecuteError)
+	require.Equal(t, "mirtex", failures.Tool)
+	require.Equal(t, 2, len(failures.Failures))
+
+	require.Equal(t, "task_1", failures.Failures[0].TaskName)
+	require.Equal(t, "mirtex", failures.Failures[0].Stage)
+	require.Equal(t, tools.STAGE_FAILED, failures.Failures[0].Status)
+	require.Equal(t, int64(139), failures.Failures[0].ExitCode)
+	require.NotEqual(t, "", failures.Failures[0].ContainerID)
+	require.Contains(t, failures.Failures[0].Message, "Segmentation fault")
+
+	require.Equal(t, "task_4", failures.Failures[1].TaskName)
+	require.Equal(t, "mirtex image is corrupt", failures.Failures[1].Message)
+
+	// the report can be read back
+	misc.CreateFolderIfNotExists(outPutDir)
+	reportPath := path.Join(outPutDir, tools.FAILURE_REPORT_NAME)
+	reportError := failures.WriteReport(reportPath)
+	require.Equal(t, nil, reportError, reportError)
+
+	reportBytes, readError := ioutil.ReadFile(reportPath)
+	require.Equal(t, nil, readError, readError)
+
+	report := tools.ExecuteError{}
+	require.Equal(t, nil, json.Unmarshal(reportBytes, &report))
+	require.Equal(t, *failures, report)
+}
@@ -1,8 +1,13 @@
 package tests
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"itextmine/misc"
+	"os"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -29,3 +34,174 @@ func TestInputDocSplit(t *testing.T) {
 	require.Equal(t, nil, lineCountError, lineCountError)
 	require.Equal(t, 20, lineCount)
 }
+
+// Test splitting of input doc by the length of the document texts
+func TestInputDocSplitByText(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_pmc.json"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	// split the document
+	charsPerTask := 5000
+	splitErr := misc.SplitInputDocWithOptions(inputDoc, workDir, "mirtex", misc.SplitOptions{CharsPerTask: charsPerTask})
+	require.Equal(t, nil, splitErr, splitErr)
+
+	taskDirNames, taskDirNamesErr := misc.GetSubDirNames(path.Join(workDir, "mirtex"))
+	require.Equal(t, nil, taskDirNamesErr, taskDirNamesErr)
+	misc.SortTaskNames(*taskDirNames)
+	require.True(t, len(*taskDirNames) > 1, "Input doc was not split")
+
+	// the tasks stay within the budget, unless a single document exceeds it
+	splitLines := make([]string, 0)
+	for _, taskDirName := range *taskDirNames {
+		taskInput, readError := ioutil.ReadFile(path.Join(workDir, "mirtex", taskDirName, "input.json"))
+		require.Equal(t, nil, readError, readError)
+
+		taskLines := strings.Split(strings.TrimSuffix(string(taskInput), "\n"), "\n")
+		taskChars := 0
+		for _, taskLine := range taskLines {
+			taskChars = taskChars + misc.TextLength(taskLine)
+		}
+		require.True(t, len(taskLines) == 1 || taskChars <= charsPerTask, "%s has %d characters", taskDirName, taskChars)
+
+		splitLines = append(splitLines, taskLines...)
+	}
+
+	// all documents are kept in order
+	inputBytes, readError := ioutil.ReadFile(inputDoc)
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, strings.Split(strings.TrimSuffix(string(inputBytes), "\n"), "\n"), splitLines)
+}
+
+// Test that the sections of a PMC article stay in the same task
+func TestInputDocSplitByGroup(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_pmc.json"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	// split the document
+	splitErr := misc.SplitInputDocWithOptions(inputDoc, workDir, "mirtex", misc.SplitOptions{LinesPerTask: 10, GroupKey: "pmcid"})
+	require.Equal(t, nil, splitErr, splitErr)
+
+	taskDirNames, taskDirNamesErr := misc.GetSubDirNames(path.Join(workDir, "mirtex"))
+	require.Equal(t, nil, taskDirNamesErr, taskDirNamesErr)
+
+	// every article is in a single task
+	articleTasks := make(map[string]string)
+	lineCount := 0
+	for _, taskDirName := range *taskDirNames {
+		docIds, readError := ReadDocIds(path.Join(workDir, "mirtex", taskDirName, "input.json"))
+		require.Equal(t, nil, readError, readError)
+
+		for _, docId := range docIds {
+			pmcid := strings.Split(docId, "-")[0]
+			articleTask, exists := articleTasks[pmcid]
+			require.True(t, exists == false || articleTask == taskDirName, "%s is split across %s and %s", pmcid, articleTask, taskDirName)
+			articleTasks[pmcid] = taskDirName
+			lineCount = lineCount + 1
+		}
+	}
+	require.Equal(t, 100, lineCount)
+	require.Equal(t, 51, len(articleTasks))
+}
+
+// Test that records of a group that are not next to each other still end up in the same task
+func TestInputDocSplitByScatteredGroup(t *testing.T) {
+	workDir := "test_workdir"
+	inputDoc := path.Join(workDir, "scattered.json")
+	defer misc.CleanDir(workDir)
+
+	inputLines := []string{
+		"{\"docId\": \"PMC1-1\", \"pmcid\": \"PMC1\"}",
+		"{\"docId\": \"PMC1-2\", \"pmcid\": \"PMC1\"}",
+		"{\"docId\": \"PMC2-1\", \"pmcid\": \"PMC2\"}",
+		"{\"docId\": \"PMC3-1\", \"pmcid\": \"PMC3\"}",
+		"{\"docId\": \"PMC1-3\", \"pmcid\": \"PMC1\"}",
+		"{\"docId\": \"PMC3-2\", \"pmcid\": \"PMC3\"}",
+	}
+	require.Equal(t, nil, os.MkdirAll(workDir, os.FileMode(0777)))
+	require.Equal(t, nil, ioutil.WriteFile(inputDoc, []byte(strings.Join(inputLines, "\n")+"\n"), os.FileMode(0666)))
+
+	splitErr := misc.SplitInputDocWithOptions(inputDoc, workDir, "mirtex", misc.SplitOptions{LinesPerTask: 2, GroupKey: "pmcid"})
+	require.Equal(t, nil, splitErr, splitErr)
+
+	taskDocIds := make([][]string, 0)
+	for taskIndex := 0; taskIndex < 2; taskIndex++ {
+		docIds, readError := ReadDocIds(path.Join(workDir, "mirtex", fmt.Sprintf("task_%d", taskIndex), "input.json"))
+		require.Equal(t, nil, readError, readError)
+		taskDocIds = append(taskDocIds, docIds)
+	}
+
+	require.Equal(t, [][]string{{"PMC1-1", "PMC1-2", "PMC1-3"}, {"PMC2-1", "PMC3-1", "PMC3-2"}}, taskDocIds)
+}
+
+// Test splitting of several input files, directories and glob patterns into one set of tasks
+func TestInputDocsSplit(t *testing.T) {
+	workDir := "test_workdir"
+	inputDir := path.Join(workDir, "daily")
+	defer misc.CleanDir(workDir)
+
+	// three daily files, one of them compressed
+	require.Equal(t, nil, os.MkdirAll(inputDir, os.FileMode(0777)))
+	for fileIndex, fileName := range []string{"day_1.json", "day_2.json", "day_3.json"} {
+		content := fmt.Sprintf("{\"docId\": \"%d1\"}\n{\"docId\": \"%d2\"}\n{\"docId\": \"%d3\"}\n", fileIndex+1, fileIndex+1, fileIndex+1)
+		require.Equal(t, nil, ioutil.WriteFile(path.Join(workDir, fileName), []byte(content), os.FileMode(0666)))
+	}
+	compressFile(t, path.Join(workDir, "day_3.json"), path.Join(inputDir, "day_3.json.gz"))
+	require.Equal(t, nil, os.Rename(path.Join(workDir, "day_2.json"), path.Join(inputDir, "day_2.json")))
+
+	inputDocPaths, resolveError := misc.ResolveInputDocs([]string{path.Join(workDir, "day_1.*"), inputDir})
+	require.Equal(t, nil, resolveError, resolveError)
+	require.Equal(t, []string{path.Join(workDir, "day_1.json"), path.Join(inputDir, "day_2.json"), path.Join(inputDir, "day_3.json.gz")}, inputDocPaths)
+
+	splitErr := misc.SplitInputDocs(inputDocPaths, workDir, "mirtex", misc.SplitOptions{LinesPerTask: 4})
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// the lines keep the order of the files
+	docIds, readError := ReadDocIds(path.Join(workDir, "mirtex", "task_1", "input.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, []string{"22", "23", "31", "32"}, docIds)
+
+	// and record where they came from
+	sourcesBytes, readError := ioutil.ReadFile(path.Join(workDir, "mirtex", "task_1", misc.SOURCES_FILE_NAME))
+	require.Equal(t, nil, readError, readError)
+
+	lineSources := make([]misc.LineSource, 0)
+	for _, sourceLine := range strings.Split(strings.TrimSuffix(string(sourcesBytes), "\n"), "\n") {
+		lineSource := misc.LineSource{}
+		require.Equal(t, nil, json.Unmarshal([]byte(sourceLine), &lineSource))
+		lineSources = append(lineSources, lineSource)
+	}
+	require.Equal(t, []misc.LineSource{
+		{Source: path.Join(inputDir, "day_2.json"), Line: 2, Index: 5},
+		{Source: path.Join(inputDir, "day_2.json"), Line: 3, Index: 6},
+		{Source: path.Join(inputDir, "day_3.json.gz"), Line: 1, Index: 7},
+		{Source: path.Join(inputDir, "day_3.json.gz"), Line: 2, Index: 8},
+	}, lineSources)
+
+	// patterns without a match are an error
+	_, resolveError = misc.ResolveInputDocs([]string{path.Join(workDir, "day_9*")})
+	require.NotEqual(t, nil, resolveError)
+}
+
+// Test splitting of an input doc read from stdin
+func TestInputDocSplitStdin(t *testing.T) {
+	inputDoc := "../data/mirtex/test_doc_in_medline.json"
+	workDir := "test_workdir"
+	defer misc.CleanDir(workDir)
+
+	inputFile, openError := os.Open(inputDoc)
+	require.Equal(t, nil, openError, openError)
+	defer inputFile.Close()
+
+	stdin := os.Stdin
+	os.Stdin = inputFile
+	defer func() { os.Stdin = stdin }()
+
+	splitErr := misc.SplitInputDocs([]string{misc.STDIN_INPUT}, workDir, "mirtex", misc.SplitOptions{LinesPerTask: 20})
+	require.Equal(t, nil, splitErr, splitErr)
+
+	taskDirNames, taskDirNamesErr := misc.GetSubDirNames(path.Join(workDir, "mirtex"))
+	require.Equal(t, nil, taskDirNamesErr, taskDirNamesErr)
+	require.Equal(t, 5, len(*taskDirNames))
+}
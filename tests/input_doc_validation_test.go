@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"itextmine/misc"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test the schema checks of the medline and pmc documents
+func TestValidateDocument(t *testing.T) {
+	// the test collections are valid
+	for inputDoc, collectionType := range map[string]string{
+		"../data/mirtex/test_doc_in_medline.json": "medline",
+		"../data/mirtex/test_doc_in_pmc.json":     misc.COLLECTION_PMC,
+		"../data/rlimsp/test_split_doc_in.json":   "medline",
+	} {
+		inputBytes, readError := ioutil.ReadFile(inputDoc)
+		require.Equal(t, nil, readError, readError)
+
+		for _, line := range strings.Split(strings.TrimSuffix(string(inputBytes), "\n"), "\n") {
+			require.Equal(t, nil, misc.ValidateDocument(line, collectionType), line)
+		}
+	}
+
+	invalidDocuments := map[string]string{
+		"{\"docId\": \"1\", \"text\": \"abc\"": "Invalid json",
+		"{\"text\": \"abc\"}":                  "Missing docId",
+		"{\"docId\": \"1\"}":                   "Missing text",
+		"{\"docId\": \"1\", \"text\": \"abc\", \"title\": {\"charStart\": 0, \"charEnd\": 4}}":                                          "Invalid title",
+		"{\"docId\": \"1\", \"text\": \"abc\", \"sentence\": [{\"charStart\": 0, \"charEnd\": 2}, {\"charStart\": 2, \"charEnd\": 1}]}": "Invalid sentence 1",
+		"{\"docId\": \"1\", \"text\": \"abc\", \"sentence\": [{\"charStart\": 0, \"charEnd\": 3}]}":                                     "Invalid sentence 0",
+		"{\"docId\": true, \"text\": \"abc\"}":                                      "docId must be a string or a number",
+		"{\"docId\": \"1\", \"text\": \"abc\", \"sentence\": [{\"charStart\": 0}]}": "Invalid sentence 0",
+		"{\"docId\": \"1\", \"text\": \"abc\", \"title_offset\": [0]}":              "Invalid title_offset",
+	}
+	for line, reason := range invalidDocuments {
+		validateError := misc.ValidateDocument(line, "medline")
+		require.NotEqual(t, nil, validateError, line)
+		require.Contains(t, validateError.Error(), reason)
+	}
+
+	// pmc sections need their article, offsets count characters and charEnd is the last character
+	require.Contains(t, misc.ValidateDocument("{\"docId\": \"PMC1-1\", \"text\": \"abc\"}", misc.COLLECTION_PMC).Error(), "Missing pmcid")
+	require.Equal(t, nil, misc.ValidateDocument("{\"docId\": \"1\", \"text\": \"αβγ\", \"title\": {\"charStart\": 0, \"charEnd\": 2}}", "medline"))
+	require.Contains(t, misc.ValidateDocument("{\"docId\": \"1\", \"text\": \"αβγ\", \"title\": {\"charStart\": 0, \"charEnd\": 3}}", "medline").Error(), "Invalid title")
+
+	// numeric docIds are accepted, like the reduce accepts them
+	require.Equal(t, nil, misc.ValidateDocument("{\"docId\": 12345, \"text\": \"abc\"}", "medline"))
+}
+
+// Test that the rejected documents are left out of the tasks and written with their reason
+func TestInputDocSplitValidation(t *testing.T) {
+	workDir := "test_workdir"
+	inputDoc := path.Join(workDir, "input.json")
+	defer misc.CleanDir(workDir)
+
+	inputLines := []string{
+		"{\"docId\": \"1\", \"text\": \"abc\"}",
+		"{\"docId\": \"2\", \"text\": \"abc\", \"title\": {\"charStart\": 0, \"charEnd\": 9}}",
+		"{\"docId\": \"3\", \"text\": \"abc\"}",
+		"{\"text\": \"abc\"}",
+	}
+	require.Equal(t, nil, os.MkdirAll(workDir, os.FileMode(0777)))
+	require.Equal(t, nil, ioutil.WriteFile(inputDoc, []byte(strings.Join(inputLines, "\n")+"\n"), os.FileMode(0666)))
+
+	splitErr := misc.SplitInputDocWithOptions(inputDoc, workDir, "mirtex", misc.SplitOptions{LinesPerTask: 10, Validate: true, CollectionType: "medline"})
+	require.Equal(t, nil, splitErr, splitErr)
+
+	docIds, readError := ReadDocIds(path.Join(workDir, "mirtex", "task_0", "input.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, []string{"1", "3"}, docIds)
+
+	rejectedBytes, readError := ioutil.ReadFile(misc.RejectedPath(workDir, "mirtex"))
+	require.Equal(t, nil, readError, readError)
+
+	rejectedLines := strings.Split(strings.TrimSuffix(string(rejectedBytes), "\n"), "\n")
+	require.Equal(t, 2, len(rejectedLines))
+
+	rejected := struct {
+		Line   int    `json:"line"`
+		Reason string `json:"reason"`
+		Record string `json:"record"`
+	}{}
+	require.Equal(t, nil, json.Unmarshal([]byte(rejectedLines[0]), &rejected))
+	require.Equal(t, 2, rejected.Line)
+	require.Contains(t, rejected.Reason, "Invalid title")
+	require.Equal(t, inputLines[1], rejected.Record)
+
+	// half of the documents were rejected
+	splitErr = misc.SplitInputDocWithOptions(inputDoc, workDir, "mirtex", misc.SplitOptions{LinesPerTask: 10, Validate: true, MaxRejectedRatio: 0.25})
+	require.NotEqual(t, nil, splitErr)
+	require.Contains(t, splitErr.Error(), "2 of 4 documents were rejected")
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"itextmine/misc"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that a missing podman socket is reported before the run starts
+func TestPodmanRuntimeMissingSocket(t *testing.T) {
+	_, runtimeError := misc.CreatePodmanRuntime("test_workdir/podman.sock")
+	require.NotEqual(t, nil, runtimeError)
+	require.Contains(t, runtimeError.Error(), "systemctl --user enable --now podman.socket")
+}
+
+// Test that the socket is looked up in the runtime dir of the user and in /run for root
+func TestPodmanSocketPath(t *testing.T) {
+	cases := []struct {
+		name       string
+		runtimeDir string
+		uid        int
+		socketPath string
+	}{
+		{"runtime dir", "/tmp/runtime-1000", 1000, "/tmp/runtime-1000/podman/podman.sock"},
+		{"missing runtime dir", "", 1000, "/run/user/1000/podman/podman.sock"},
+		{"root", "", 0, "/run/podman/podman.sock"},
+		{"root with runtime dir", "/run/user/0", 0, "/run/podman/podman.sock"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.socketPath, misc.PodmanSocketPath(c.runtimeDir, c.uid))
+		})
+	}
+}
+
+// Test that the selinux relabel option is added to the binds with and without options
+func TestBindsWithOptions(t *testing.T) {
+	cases := []struct {
+		name    string
+		binds   []string
+		options string
+		result  []string
+	}{
+		{"no options", []string{"/data/task:/input"}, "", []string{"/data/task:/input"}},
+		{"relabel", []string{"/data/task:/input", "/data/out:/output"}, "z", []string{"/data/task:/input:z", "/data/out:/output:z"}},
+		{"relabel read only", []string{"/data/task:/input:ro"}, "z", []string{"/data/task:/input:ro,z"}},
+		{"no binds", []string{}, "z", []string{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.result, misc.BindsWithOptions(c.binds, c.options))
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"fmt"
+	"io/ioutil"
+	"itextmine/misc"
+	"itextmine/tools"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTaskFile writes a task file of the mirtex workdir
+func writeTaskFile(t *testing.T, workDir string, taskName string, fileName string, content string) {
+	taskDir := path.Join(workDir, "mirtex", taskName)
+	require.Equal(t, nil, os.MkdirAll(taskDir, os.FileMode(0777)))
+	require.Equal(t, nil, ioutil.WriteFile(path.Join(taskDir, fileName), []byte(content), os.FileMode(0666)))
+}
+
+// Test that the task outputs are concatenated line by line, also from paths with spaces
+func TestReduce(t *testing.T) {
+	workDir := "test workdir"
+	outPutDir := "output dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	// task_1 has no trailing newline, task_2 has no aligned output
+	writeTaskFile(t, workDir, "task_0", "output.json", "{\"docId\": \"1\"}\n{\"docId\": \"2\"}\n")
+	writeTaskFile(t, workDir, "task_0", "align.json", "{\"docId\": \"1\", \"aligned\": true}\n")
+	writeTaskFile(t, workDir, "task_1", "output.json", "{\"docId\": \"3\"}")
+	writeTaskFile(t, workDir, "task_1", "align.json", "\n{\"docId\": \"3\", \"aligned\": true}")
+	writeTaskFile(t, workDir, "task_2", "output.json", "")
+
+	reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "medline", tools.ReduceOptions{})
+	require.Equal(t, nil, reduceError, reduceError)
+
+	mirtexOutput, readError := ioutil.ReadFile(path.Join(outPutDir, "mirtex.medline.output.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, "{\"docId\": \"1\"}\n{\"docId\": \"2\"}\n{\"docId\": \"3\"}\n", string(mirtexOutput))
+
+	alignOutput, readError := ioutil.ReadFile(path.Join(outPutDir, "mirtex.medline.align.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, "{\"docId\": \"1\", \"aligned\": true}\n{\"docId\": \"3\", \"aligned\": true}\n", string(alignOutput))
+}
+
+// Test that corrupt lines are left out and reported with their task
+func TestReduceCorruptLines(t *testing.T) {
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	writeTaskFile(t, workDir, "task_0", "output.json", "{\"docId\": \"1\"}\n")
+	writeTaskFile(t, workDir, "task_0", "align.json", "{\"docId\": \"1\"\n")
+	writeTaskFile(t, workDir, "task_1", "output.json", "{\"docId\": \"2\"}\n{\"docId\": \"3\n{\"docId\": \"4\"}\n")
+	writeTaskFile(t, workDir, "task_1", "align.json", "{\"docId\": \"2\"}\n")
+
+	reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "medline", tools.ReduceOptions{})
+	require.NotEqual(t, nil, reduceError)
+	require.Contains(t, reduceError.Error(), "2 of 2 reduce outputs have errors, 2 corrupt lines left out")
+	require.Contains(t, reduceError.Error(), "task_1/output.json:2")
+
+	// the corrupt lines of every output are reported
+	aggregatedError, isReduceError := reduceError.(*tools.ReduceError)
+	require.True(t, isReduceError)
+	require.Equal(t, []tools.CorruptLine{
+		{TaskName: "task_1", TaskFile: "output.json", LineNumber: 2},
+		{TaskName: "task_0", TaskFile: "align.json", LineNumber: 1},
+	}, aggregatedError.CorruptLines())
+
+	// the outputs after the first one with corrupt lines are written too
+	alignOutput, readError := ioutil.ReadFile(path.Join(outPutDir, "mirtex.medline.align.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, "{\"docId\": \"2\"}\n", string(alignOutput))
+
+	mirtexOutput, readError := ioutil.ReadFile(path.Join(outPutDir, "mirtex.medline.output.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, "{\"docId\": \"1\"}\n{\"docId\": \"2\"}\n{\"docId\": \"4\"}\n", string(mirtexOutput))
+}
+
+// Test that the records keep the input doc order, task_2 before task_10, or are sorted by docId
+func TestReduceOrder(t *testing.T) {
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	for taskIndex := 0; taskIndex < 12; taskIndex++ {
+		taskName := fmt.Sprintf("task_%d", taskIndex)
+		docId := 100 - taskIndex
+		writeTaskFile(t, workDir, taskName, "output.json", fmt.Sprintf("{\"docId\": \"%d\", \"line\": 1}\n{\"docId\": \"%d\", \"line\": 2}\n", docId, docId))
+		writeTaskFile(t, workDir, taskName, "align.json", fmt.Sprintf("{\"docId\": \"%d\"}\n", docId))
+	}
+
+	// input doc order
+	reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "medline", tools.ReduceOptions{})
+	require.Equal(t, nil, reduceError, reduceError)
+
+	docIds, readError := ReadDocIds(path.Join(outPutDir, "mirtex.medline.align.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, []string{"100", "99", "98", "97", "96", "95", "94", "93", "92", "91", "90", "89"}, docIds)
+
+	// docId order, records of the same doc stay in line order
+	reduceError = tools.Reduce(workDir, outPutDir, "mirtex", "medline", tools.ReduceOptions{SortByDocId: true})
+	require.Equal(t, nil, reduceError, reduceError)
+
+	docIds, readError = ReadDocIds(path.Join(outPutDir, "mirtex.medline.align.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, []string{"89", "90", "91", "92", "93", "94", "95", "96", "97", "98", "99", "100"}, docIds)
+
+	mirtexOutput, readError := ioutil.ReadFile(path.Join(outPutDir, "mirtex.medline.output.json"))
+	require.Equal(t, nil, readError, readError)
+	require.True(t, strings.HasPrefix(string(mirtexOutput), "{\"docId\": \"89\", \"line\": 1}\n{\"docId\": \"89\", \"line\": 2}\n{\"docId\": \"90\", \"line\": 1}\n"))
+}
+
+// Test that the docIds are sorted by their numbers and text, the same way whatever the order of the records
+func TestReduceSortByDocIdRuns(t *testing.T) {
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	sortedDocIds := []string{"9", "10", "10a", "PMC1-1", "PMC1-2", "PMC1-10", "PMC2-1", "PMC10-1", "a"}
+	for _, docIds := range [][]string{
+		{"PMC1-10", "10a", "PMC1-2", "9", "a", "PMC10-1", "10", "PMC2-1", "PMC1-1"},
+		{"10", "9", "10a", "PMC2-1", "PMC1-1", "a", "PMC10-1", "PMC1-10", "PMC1-2"},
+	} {
+		for taskIndex, docId := range docIds {
+			taskName := fmt.Sprintf("task_%d", taskIndex)
+			writeTaskFile(t, workDir, taskName, "output.json", fmt.Sprintf("{\"docId\": \"%s\"}\n", docId))
+			writeTaskFile(t, workDir, taskName, "align.json", fmt.Sprintf("{\"docId\": \"%s\"}\n", docId))
+		}
+
+		reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "pmc", tools.ReduceOptions{SortByDocId: true})
+		require.Equal(t, nil, reduceError, reduceError)
+
+		reducedDocIds, readError := ReadDocIds(path.Join(outPutDir, "mirtex.pmc.align.json"))
+		require.Equal(t, nil, readError, readError)
+		require.Equal(t, sortedDocIds, reducedDocIds)
+	}
+}
+
+// Test that the records of a group appended to an earlier task are reduced in the input doc order
+func TestReduceLateGroupRecords(t *testing.T) {
+	workDir := "test_workdir"
+	outPutDir := "output_dir"
+	inputDoc := path.Join(workDir, "scattered.json")
+
+	defer misc.CleanDir(workDir)
+	defer misc.CleanDir(outPutDir)
+
+	inputDocIds := []string{"PMC1-1", "PMC1-2", "PMC2-1", "PMC3-1", "PMC1-3", "PMC3-2"}
+	inputLines := make([]string, 0, len(inputDocIds))
+	for _, docId := range inputDocIds {
+		inputLines = append(inputLines, fmt.Sprintf("{\"docId\": \"%s\", \"pmcid\": \"%s\"}", docId, strings.Split(docId, "-")[0]))
+	}
+	require.Equal(t, nil, os.MkdirAll(workDir, os.FileMode(0777)))
+	require.Equal(t, nil, ioutil.WriteFile(inputDoc, []byte(strings.Join(inputLines, "\n")+"\n"), os.FileMode(0666)))
+
+	// PMC1-3 is appended to task_0
+	splitErr := misc.SplitInputDocWithOptions(inputDoc, workDir, "mirtex", misc.SplitOptions{LinesPerTask: 2, GroupKey: "pmcid"})
+	require.Equal(t, nil, splitErr, splitErr)
+
+	// the tools write their records in the order of the task input, PMC1-3 has two records
+	writeTaskFile(t, workDir, "task_0", "output.json", "{\"docId\": \"PMC1-1\"}\n{\"docId\": \"PMC1-2\"}\n{\"docId\": \"PMC1-3\", \"line\": 1}\n{\"docId\": \"PMC1-3\", \"line\": 2}\n")
+	writeTaskFile(t, workDir, "task_1", "output.json", "{\"docId\": \"PMC2-1\"}\n{\"docId\": \"PMC3-1\"}\n{\"docId\": \"PMC3-2\"}\n")
+	writeTaskFile(t, workDir, "task_0", "align.json", "{\"docId\": \"PMC1-1\"}\n{\"docId\": \"PMC1-2\"}\n{\"docId\": \"PMC1-3\"}\n")
+	writeTaskFile(t, workDir, "task_1", "align.json", "{\"docId\": \"PMC2-1\"}\n{\"docId\": \"PMC3-1\"}\n{\"docId\": \"PMC3-2\"}\n")
+
+	reduceError := tools.Reduce(workDir, outPutDir, "mirtex", "pmc", tools.ReduceOptions{})
+	require.Equal(t, nil, reduceError, reduceError)
+
+	docIds, readError := ReadDocIds(path.Join(outPutDir, "mirtex.pmc.align.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, inputDocIds, docIds)
+
+	mirtexOutput, readError := ioutil.ReadFile(path.Join(outPutDir, "mirtex.pmc.output.json"))
+	require.Equal(t, nil, readError, readError)
+	require.Equal(t, "{\"docId\": \"PMC1-1\"}\n{\"docId\": \"PMC1-2\"}\n{\"docId\": \"PMC2-1\"}\n{\"docId\": \"PMC3-1\"}\n{\"docId\": \"PMC1-3\", \"line\": 1}\n{\"docId\": \"PMC1-3\", \"line\": 2}\n{\"docId\": \"PMC3-2\"}\n", string(mirtexOutput))
+}
+
+// Test the numeric order of the task folders
+func TestSortTaskNames(t *testing.T) {
+	taskNames := []string{"task_10", "task_2", "rlimsp.checkpoint.json", "task_1", "task_0"}
+	misc.SortTaskNames(taskNames)
+	require.Equal(t, []string{"task_0", "task_1", "task_2", "task_10", "rlimsp.checkpoint.json"}, taskNames)
+}
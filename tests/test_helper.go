@@ -2,7 +2,17 @@ package tests
 
 import (
 	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"itextmine/misc"
+	"itextmine/tools"
 	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func CountLines(filePath string) (int, error) {
@@ -33,3 +43,109 @@ func CountLines(filePath string) (int, error) {
 	return lineIndex, nil
 
 }
+
+// FakeToolRunner returns a fake container that writes one result line per document of the input mount
+func FakeToolRunner(inputTarget string, outputTarget string) misc.FakeRunner {
+	return func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		docIds, readError := ReadDocIds(mounts[inputTarget])
+		if readError != nil {
+			return 1, readError
+		}
+
+		outputLines := make([]string, 0, len(docIds))
+		for _, docId := range docIds {
+			outputLines = append(outputLines, fmt.Sprintf("{\"docId\": %q, \"tool\": %q}", docId, spec.Image))
+		}
+
+		writeError := ioutil.WriteFile(mounts[outputTarget], []byte(strings.Join(outputLines, "\n")+"\n"), 0666)
+		if writeError != nil {
+			return 1, writeError
+		}
+
+		fmt.Fprintf(logs, "%s processed %d documents\n", spec.Image, len(docIds))
+		return 0, nil
+	}
+}
+
+// FakeRlimspRunner returns a fake rlimsp container that writes the json and the text output read by efip
+func FakeRlimspRunner() misc.FakeRunner {
+	return func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		exitCode, runError := FakeToolRunner("/rlims_workdir/in.json", "/rlims_workdir/out.json")(spec, mounts, logs)
+		if runError != nil {
+			return exitCode, runError
+		}
+		return FakeToolRunner("/rlims_workdir/in.json", "/rlims_workdir/out.txt")(spec, mounts, logs)
+	}
+}
+
+// FakeRlimspRuntime returns a fake runtime scripted for the rlimsp, efip and align containers
+func FakeRlimspRuntime() *misc.FakeRuntime {
+	fakeRuntime := misc.NewFakeRuntime()
+	fakeRuntime.SetRunner("itextmine/rlimsp", FakeRlimspRunner())
+	fakeRuntime.SetRunner("leebird/efip", FakeToolRunner("/efip_workdir/docs.rlims.txt", "/efip_workdir/docs.json"))
+	fakeRuntime.SetRunner("itextmine/align", FakeAlignRunner())
+	return fakeRuntime
+}
+
+// NoSidecarDelay starts the sidecars of the tool without their startup delay until the end of the test, the fake
+// sidecars are up right away
+func NoSidecarDelay(t *testing.T, toolName string) {
+	toolConfig, toolConfigError := tools.GetToolConfig(toolName)
+	require.Equal(t, nil, toolConfigError, toolConfigError)
+
+	noDelay := tools.ToolConfig{Sidecars: make(map[string]tools.SidecarConfig)}
+	delay := tools.ToolConfig{Sidecars: make(map[string]tools.SidecarConfig)}
+	for sidecarName, sidecarConfig := range toolConfig.Sidecars {
+		noDelay.Sidecars[sidecarName] = tools.SidecarConfig{StartupDelay: "0s"}
+		delay.Sidecars[sidecarName] = tools.SidecarConfig{StartupDelay: sidecarConfig.StartupDelay}
+	}
+
+	configureError := tools.ConfigureTool(toolName, noDelay)
+	require.Equal(t, nil, configureError, configureError)
+	t.Cleanup(func() {
+		tools.ConfigureTool(toolName, delay)
+	})
+}
+
+// FakeAlignRunner returns a fake align container that copies the tool result to the aligned output
+func FakeAlignRunner() misc.FakeRunner {
+	return func(spec misc.ContainerSpec, mounts map[string]string, logs io.Writer) (int64, error) {
+		result, readError := ioutil.ReadFile(mounts["/align_workdir/result_file.json"])
+		if readError != nil {
+			return 1, readError
+		}
+
+		writeError := ioutil.WriteFile(mounts["/align_workdir/output_file.json"], result, 0666)
+		if writeError != nil {
+			return 1, writeError
+		}
+		return 0, nil
+	}
+}
+
+func ReadDocIds(filePath string) ([]string, error) {
+	inputfile, inputFileOpenError := os.Open(filePath)
+	if inputFileOpenError != nil {
+		return nil, inputFileOpenError
+	}
+	defer inputfile.Close()
+
+	scanner := bufio.NewScanner(inputfile)
+	const maxCapacity = 512 * 1024 // 512KB
+	buffer := make([]byte, maxCapacity)
+	scanner.Buffer(buffer, maxCapacity)
+
+	docIds := make([]string, 0)
+	for scanner.Scan() {
+		doc := struct {
+			DocId string `json:"docId"`
+		}{}
+		unmarshalError := json.Unmarshal(scanner.Bytes(), &doc)
+		if unmarshalError != nil {
+			return nil, unmarshalError
+		}
+		docIds = append(docIds, doc.DocId)
+	}
+
+	return docIds, scanner.Err()
+}
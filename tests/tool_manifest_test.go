@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"io/ioutil"
+	"itextmine/tools"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test loading tools from manifest files
+func TestLoadManifestDir(t *testing.T) {
+	loadError := tools.LoadManifestDir("../data/manifests")
+	require.Equal(t, nil, loadError, loadError)
+
+	// yaml manifest
+	exampleTool, exampleToolError := tools.GetTool("exampletool")
+	require.Equal(t, nil, exampleToolError, exampleToolError)
+	require.Equal(t, []string{"exampletool", "exampletool-align"}, exampleTool.Stages())
+	require.Equal(t, []string{"itextmine/exampletool", "itextmine/align"}, exampleTool.Images())
+	require.Equal(t, []string{"^/exampletool-task", "^/exampletool-align"}, exampleTool.CleanupPatterns())
+	require.Equal(t, 2, len(exampleTool.ReduceOutputs()))
+
+	// json manifest with a network and a sidecar
+	sidecarTool, sidecarToolError := tools.GetTool("examplesidecartool")
+	require.Equal(t, nil, sidecarToolError, sidecarToolError)
+	_, isSidecarTool := sidecarTool.(tools.SidecarTool)
+	require.True(t, isSidecarTool)
+	require.Equal(t, []string{"^/examplesidecartool-task", "^/examplesidecartool-db$"}, sidecarTool.CleanupPatterns())
+}
+
+// Test that invalid manifests are rejected
+func TestLoadInvalidManifest(t *testing.T) {
+	manifestDir, tempDirError := ioutil.TempDir("", "manifests")
+	require.Equal(t, nil, tempDirError, tempDirError)
+	defer os.RemoveAll(manifestDir)
+
+	// mounts cannot point outside of the task folder
+	manifestPath := path.Join(manifestDir, "invalid.yaml")
+	manifest := `
+name: invalidtool
+stages:
+  - name: invalidtool
+    image: itextmine/invalidtool
+    mounts:
+      - source: ../../etc/passwd
+        target: /workdir/in.json
+`
+	writeError := ioutil.WriteFile(manifestPath, []byte(manifest), 0666)
+	require.Equal(t, nil, writeError, writeError)
+
+	_, manifestError := tools.LoadManifest(manifestPath)
+	require.NotEqual(t, nil, manifestError)
+
+	// the aligner is container or native
+	manifest = `
+name: invalidtool
+stages:
+  - name: invalidtool
+    image: itextmine/invalidtool
+    output: output.json
+    align:
+      output: align.json
+      aligner: python
+`
+	writeError = ioutil.WriteFile(manifestPath, []byte(manifest), 0666)
+	require.Equal(t, nil, writeError, writeError)
+
+	_, manifestError = tools.LoadManifest(manifestPath)
+	require.NotEqual(t, nil, manifestError)
+}
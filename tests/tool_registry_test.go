@@ -0,0 +1,27 @@
+package tests
+
+import (
+	"itextmine/tools"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the built in tools are registered
+func TestToolRegistry(t *testing.T) {
+	require.Contains(t, tools.ToolNames(), "rlimsp")
+	require.Contains(t, tools.ToolNames(), "mirtex")
+
+	// look up a registered tool
+	rlimspTool, rlimspToolError := tools.GetTool("rlimsp")
+	require.Equal(t, nil, rlimspToolError, rlimspToolError)
+	require.Equal(t, []string{"rlimsp", "rlimsp-align", "efip", "efip-align"}, rlimspTool.Stages())
+
+	// unknown tools are an error
+	_, unknownToolError := tools.GetTool("unknown")
+	require.NotEqual(t, nil, unknownToolError)
+
+	// tools cannot be registered twice
+	registerError := tools.RegisterTool(rlimspTool)
+	require.NotEqual(t, nil, registerError)
+}
@@ -0,0 +1,399 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"itextmine/misc"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ALIGNER_CONTAINER runs the itextmine/align image for every task
+	ALIGNER_CONTAINER string = "container"
+
+	// ALIGNER_NATIVE aligns the tool output in process
+	ALIGNER_NATIVE string = "native"
+)
+
+// ALIGN_LOOKAHEAD is how far the aligner looks for a character that the tool dropped or inserted
+const ALIGN_LOOKAHEAD int = 16
+
+// AlignStats counts what the native aligner did with the output of a task
+type AlignStats struct {
+	Documents        int
+	MissingDocuments int
+	Spans            int
+	RelocatedSpans   int
+	DroppedSpans     int
+
+	// UnalignedDocuments are the original documents that the tool output has no record of
+	UnalignedDocuments int
+}
+
+func (stats AlignStats) String() string {
+	return fmt.Sprintf("aligned %d documents, %d documents not found in the original, %d original documents without tool output, %d spans, %d spans relocated, %d spans dropped",
+		stats.Documents, stats.MissingDocuments, stats.UnalignedDocuments, stats.Spans, stats.RelocatedSpans, stats.DroppedSpans)
+}
+
+// AlignFiles joins the tool output to the original documents by docId and writes the aligned output.
+// Every object of a record with a charStart and a charEnd is a span of the text, charEnd being the offset of its
+// last character. The spans are mapped from the text the tool saw to the original text and checked against their
+// entityText or text when they have one. Spans that cannot be found in the original text are dropped.
+//
+// The aligned output is meant to be the align.json of the align container, TestAlignFilesContainer compares the two
+// byte for byte and needs docker: one line per tool record whose docId is in the original, in the order of the tool output, with the keys
+// of the tool record sorted, the text of the original document as text and the spans moved to the original text.
+// Tool records without an original document and original documents without a tool record are not written, they are
+// counted in the stats.
+func AlignFiles(originalJsonPath string, toolOutputJsonPath string, alignedJsonPath string) (AlignStats, error) {
+	stats := AlignStats{}
+
+	originalTexts, originalError := readOriginalTexts(originalJsonPath)
+	if originalError != nil {
+		return stats, originalError
+	}
+
+	toolOutputFile, toolOutputOpenError := os.Open(toolOutputJsonPath)
+	if toolOutputOpenError != nil {
+		return stats, toolOutputOpenError
+	}
+	defer toolOutputFile.Close()
+
+	alignedFile, alignedCreateError := os.Create(alignedJsonPath)
+	if alignedCreateError != nil {
+		return stats, alignedCreateError
+	}
+	defer alignedFile.Close()
+
+	alignedDocIds := make(map[string]bool)
+	reader := bufio.NewReader(toolOutputFile)
+	writer := bufio.NewWriter(alignedFile)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readError := reader.ReadBytes('\n')
+		if readError != nil && readError != io.EOF {
+			return stats, readError
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			alignedLine, alignError := alignRecord(line, originalTexts, alignedDocIds, &stats)
+			if alignError != nil {
+				return stats, errors.New(fmt.Sprintf("%s:%d: %s", toolOutputJsonPath, lineNumber, alignError.Error()))
+			}
+
+			if alignedLine != nil {
+				writer.Write(alignedLine)
+				writer.WriteByte('\n')
+			}
+		}
+
+		if readError == io.EOF {
+			break
+		}
+	}
+
+	flushError := writer.Flush()
+	if flushError != nil {
+		return stats, flushError
+	}
+
+	stats.UnalignedDocuments = len(originalTexts) - len(alignedDocIds)
+
+	return stats, alignedFile.Close()
+}
+
+// executeNativeAlign aligns a task in process and keeps the stats in the stage log
+func executeNativeAlign(taskName string, originalJsonPath string, toolOutputJsonPath string, alignedJsonPath string, logPath string) error {
+	stats, alignError := AlignFiles(originalJsonPath, toolOutputJsonPath, alignedJsonPath)
+	if alignError != nil {
+		return alignError
+	}
+
+	logError := ioutil.WriteFile(logPath, []byte(stats.String()+"\n"), os.FileMode(0666))
+	if logError != nil {
+		return logError
+	}
+
+	if stats.MissingDocuments > 0 || stats.UnalignedDocuments > 0 || stats.DroppedSpans > 0 {
+		log.Println(fmt.Sprintf("WARN: %s %s", taskName, stats.String()))
+	}
+
+	return nil
+}
+
+// readOriginalTexts reads the text of every document of the task input by docId
+func readOriginalTexts(originalJsonPath string) (map[string]string, error) {
+	originalFile, originalOpenError := os.Open(originalJsonPath)
+	if originalOpenError != nil {
+		return nil, originalOpenError
+	}
+	defer originalFile.Close()
+
+	originalTexts := make(map[string]string)
+	reader := bufio.NewReader(originalFile)
+	for {
+		line, readError := reader.ReadBytes('\n')
+		if readError != nil && readError != io.EOF {
+			return nil, readError
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			document := struct {
+				DocId interface{} `json:"docId"`
+				Text  string      `json:"text"`
+			}{}
+			unmarshalError := json.Unmarshal(line, &document)
+			if unmarshalError != nil {
+				return nil, errors.New(fmt.Sprintf("%s: %s", originalJsonPath, unmarshalError.Error()))
+			}
+			docId, docIdError := misc.DocIdString(document.DocId)
+			if docIdError != nil {
+				return nil, errors.New(fmt.Sprintf("%s: %s", originalJsonPath, docIdError.Error()))
+			}
+			originalTexts[docId] = document.Text
+		}
+
+		if readError == io.EOF {
+			return originalTexts, nil
+		}
+	}
+}
+
+// alignRecord aligns a record of the tool output, records without an original document are left out
+func alignRecord(line []byte, originalTexts map[string]string, alignedDocIds map[string]bool, stats *AlignStats) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	record := make(map[string]interface{})
+	decodeError := decoder.Decode(&record)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	// the docIds are read like the reduce reads them
+	docId, docIdError := lineDocId(line)
+	originalText, originalFound := originalTexts[docId]
+	if docIdError != nil || originalFound == false {
+		stats.MissingDocuments = stats.MissingDocuments + 1
+		return nil, nil
+	}
+
+	// the tool may have normalized the text, its offsets are mapped back to the original
+	toolText, hasToolText := record["text"].(string)
+	if hasToolText == false {
+		toolText = originalText
+	}
+
+	aligner := newTextAligner([]rune(toolText), []rune(originalText))
+	for key, value := range record {
+		if key == "text" || key == "docId" {
+			continue
+		}
+
+		alignedValue, keep := aligner.alignValue(value, stats)
+		if keep {
+			record[key] = alignedValue
+		} else {
+			delete(record, key)
+		}
+	}
+
+	record["text"] = originalText
+	stats.Documents = stats.Documents + 1
+	alignedDocIds[docId] = true
+
+	alignedLine := bytes.Buffer{}
+	encoder := json.NewEncoder(&alignedLine)
+	encoder.SetEscapeHTML(false)
+	encodeError := encoder.Encode(record)
+	if encodeError != nil {
+		return nil, encodeError
+	}
+
+	return bytes.TrimRight(alignedLine.Bytes(), "\n"), nil
+}
+
+// textAligner maps the character offsets of the text a tool saw to the original text
+type textAligner struct {
+	originalText []rune
+
+	// offsets maps every character of the tool text to the original text, -1 for characters the tool inserted
+	offsets []int
+}
+
+func newTextAligner(toolText []rune, originalText []rune) *textAligner {
+	offsets := make([]int, len(toolText))
+	toolIndex, originalIndex := 0, 0
+	for toolIndex < len(toolText) {
+		if originalIndex >= len(originalText) {
+			offsets[toolIndex] = -1
+			toolIndex = toolIndex + 1
+			continue
+		}
+
+		toolChar, originalChar := toolText[toolIndex], originalText[originalIndex]
+		switch {
+		case toolChar == originalChar:
+			offsets[toolIndex] = originalIndex
+			toolIndex, originalIndex = toolIndex+1, originalIndex+1
+		case unicode.IsSpace(toolChar) && unicode.IsSpace(originalChar):
+			offsets[toolIndex] = originalIndex
+			toolIndex, originalIndex = toolIndex+1, originalIndex+1
+		case unicode.IsSpace(toolChar):
+			// the tool inserted a space
+			offsets[toolIndex] = -1
+			toolIndex = toolIndex + 1
+		case unicode.IsSpace(originalChar):
+			// the tool dropped a space
+			originalIndex = originalIndex + 1
+		default:
+			// the nearest resync point decides between a dropped, an inserted and a replaced character
+			dropped := indexOfRune(originalText, originalIndex+1, toolChar)
+			inserted := indexOfRune(toolText, toolIndex+1, originalChar)
+			if dropped >= 0 && (inserted < 0 || dropped-originalIndex <= inserted-toolIndex) {
+				originalIndex = dropped
+			} else if inserted >= 0 {
+				for ; toolIndex < inserted; toolIndex++ {
+					offsets[toolIndex] = -1
+				}
+			} else {
+				offsets[toolIndex] = originalIndex
+				toolIndex, originalIndex = toolIndex+1, originalIndex+1
+			}
+		}
+	}
+
+	return &textAligner{originalText: originalText, offsets: offsets}
+}
+
+// indexOfRune looks for a character within the lookahead, -1 when it is not found
+func indexOfRune(text []rune, start int, char rune) int {
+	for index := start; index < len(text) && index < start+ALIGN_LOOKAHEAD; index++ {
+		if text[index] == char {
+			return index
+		}
+	}
+	return -1
+}
+
+// alignValue aligns the spans nested in a json value. keep is false when the value is a span that cannot be aligned.
+func (aligner *textAligner) alignValue(value interface{}, stats *AlignStats) (interface{}, bool) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		if isSpan(typedValue) {
+			stats.Spans = stats.Spans + 1
+			if aligner.alignSpan(typedValue, stats) == false {
+				stats.DroppedSpans = stats.DroppedSpans + 1
+				return nil, false
+			}
+		}
+
+		for key, nestedValue := range typedValue {
+			alignedValue, keep := aligner.alignValue(nestedValue, stats)
+			if keep {
+				typedValue[key] = alignedValue
+			} else {
+				delete(typedValue, key)
+			}
+		}
+		return typedValue, true
+	case []interface{}:
+		alignedValues := make([]interface{}, 0, len(typedValue))
+		for _, nestedValue := range typedValue {
+			alignedValue, keep := aligner.alignValue(nestedValue, stats)
+			if keep {
+				alignedValues = append(alignedValues, alignedValue)
+			}
+		}
+		return alignedValues, true
+	default:
+		return value, true
+	}
+}
+
+func isSpan(object map[string]interface{}) bool {
+	_, startIsNumber := object["charStart"].(json.Number)
+	_, endIsNumber := object["charEnd"].(json.Number)
+	return startIsNumber && endIsNumber
+}
+
+// alignSpan maps the offsets of a span to the original text, false when the span is not found in the original text
+func (aligner *textAligner) alignSpan(span map[string]interface{}, stats *AlignStats) bool {
+	charStart, startError := span["charStart"].(json.Number).Int64()
+	charEnd, endError := span["charEnd"].(json.Number).Int64()
+	if startError != nil || endError != nil || charStart < 0 || charEnd < charStart {
+		return false
+	}
+
+	// the first and last characters of the span that are in the original
+	originalStart, originalEnd := -1, -1
+	for toolIndex := int(charStart); toolIndex <= int(charEnd) && toolIndex < len(aligner.offsets); toolIndex++ {
+		if aligner.offsets[toolIndex] >= 0 {
+			if originalStart < 0 {
+				originalStart = aligner.offsets[toolIndex]
+			}
+			originalEnd = aligner.offsets[toolIndex]
+		}
+	}
+
+	// verify the span against the text the tool reported for it
+	spanText, hasSpanText := span["entityText"].(string)
+	if hasSpanText == false {
+		spanText, hasSpanText = span["text"].(string)
+	}
+
+	if hasSpanText && len(spanText) > 0 {
+		if originalStart < 0 || sameText(string(aligner.originalText[originalStart:originalEnd+1]), spanText) == false {
+			// look for the span text closest to where the tool put it
+			relocatedStart := aligner.find(spanText, originalStart)
+			if relocatedStart < 0 {
+				return false
+			}
+			stats.RelocatedSpans = stats.RelocatedSpans + 1
+			originalStart = relocatedStart
+			originalEnd = relocatedStart + len([]rune(spanText)) - 1
+		}
+	} else if originalStart < 0 {
+		return false
+	}
+
+	span["charStart"] = json.Number(fmt.Sprintf("%d", originalStart))
+	span["charEnd"] = json.Number(fmt.Sprintf("%d", originalEnd))
+	return true
+}
+
+// find returns the occurrence of text in the original text closest to near, -1 when there is none
+func (aligner *textAligner) find(text string, near int) int {
+	textRunes := []rune(text)
+	closest := -1
+	for start := 0; start+len(textRunes) <= len(aligner.originalText); start++ {
+		if string(aligner.originalText[start:start+len(textRunes)]) != text {
+			continue
+		}
+		if closest < 0 || absInt(start-near) < absInt(closest-near) {
+			closest = start
+		}
+	}
+	return closest
+}
+
+// sameText compares a span of the original text to the text a tool reported, ignoring differences in white space
+func sameText(originalText string, spanText string) bool {
+	return strings.Join(strings.Fields(originalText), " ") == strings.Join(strings.Fields(spanText), " ")
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"itextmine/misc"
+	"os"
+	"sync"
+)
+
+const (
+	STAGE_DONE      string = "done"
+	STAGE_FAILED    string = "failed"
+	STAGE_TIMED_OUT string = "timed_out"
+)
+
+// Checkpoint records the stages completed by every task, so that a run can be resumed
+type Checkpoint struct {
+	mutex    sync.Mutex
+	filePath string
+
+	Tool  string                       `json:"tool"`
+	Tasks map[string]map[string]string `json:"tasks"`
+}
+
+func CheckpointPath(workDir string, toolName string) string {
+	return misc.ToolFilePath(workDir, toolName, "checkpoint.json")
+}
+
+func NewCheckpoint(filePath string, toolName string) *Checkpoint {
+	return &Checkpoint{
+		filePath: filePath,
+		Tool:     toolName,
+		Tasks:    make(map[string]map[string]string),
+	}
+}
+
+func LoadCheckpoint(filePath string, toolName string) (*Checkpoint, error) {
+	checkpointBytes, readError := ioutil.ReadFile(filePath)
+	if os.IsNotExist(readError) {
+		// nothing was recorded yet
+		return NewCheckpoint(filePath, toolName), nil
+	} else if readError != nil {
+		return nil, readError
+	}
+
+	checkpoint := NewCheckpoint(filePath, toolName)
+	unmarshalError := json.Unmarshal(checkpointBytes, checkpoint)
+	if unmarshalError != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", filePath, unmarshalError.Error()))
+	}
+
+	if checkpoint.Tool != toolName {
+		return nil, errors.New(fmt.Sprintf("%s was recorded for tool %s and cannot be resumed with %s", filePath, checkpoint.Tool, toolName))
+	}
+
+	if checkpoint.Tasks == nil {
+		checkpoint.Tasks = make(map[string]map[string]string)
+	}
+
+	return checkpoint, nil
+}
+
+func (checkpoint *Checkpoint) IsDone(taskName string, stage string) bool {
+	checkpoint.mutex.Lock()
+	defer checkpoint.mutex.Unlock()
+
+	return checkpoint.Tasks[taskName][stage] == STAGE_DONE
+}
+
+func (checkpoint *Checkpoint) Mark(taskName string, stage string, status string) error {
+	checkpoint.mutex.Lock()
+	defer checkpoint.mutex.Unlock()
+
+	if checkpoint.Tasks[taskName] == nil {
+		checkpoint.Tasks[taskName] = make(map[string]string)
+	}
+	checkpoint.Tasks[taskName][stage] = status
+
+	return checkpoint.save()
+}
+
+func (checkpoint *Checkpoint) Save() error {
+	checkpoint.mutex.Lock()
+	defer checkpoint.mutex.Unlock()
+
+	return checkpoint.save()
+}
+
+func (checkpoint *Checkpoint) save() error {
+	checkpointBytes, marshalError := json.MarshalIndent(checkpoint, "", "  ")
+	if marshalError != nil {
+		return marshalError
+	}
+
+	// write to a temp file and rename it, so that a crash never leaves a half written checkpoint
+	tempFilePath := checkpoint.filePath + ".tmp"
+	writeError := ioutil.WriteFile(tempFilePath, checkpointBytes, os.FileMode(0666))
+	if writeError != nil {
+		return writeError
+	}
+
+	return os.Rename(tempFilePath, checkpoint.filePath)
+}
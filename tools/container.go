@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"itextmine/misc"
+	"log"
+	"os"
+	"strings"
+)
+
+// LOG_TAIL_LINES is the number of log lines included in the error of a failed container
+const LOG_TAIL_LINES int = 20
+
+// ExitError is returned for a stage container that exited with a non zero code
+type ExitError struct {
+	Stage       string
+	TaskName    string
+	ContainerID string
+	ExitCode    int64
+
+	// LogTail holds the last lines of the container logs
+	LogTail string
+}
+
+func (exitError *ExitError) Error() string {
+	return fmt.Sprintf("%s of %s exited with code %d:\n%s", exitError.Stage, exitError.TaskName, exitError.ExitCode, exitError.LogTail)
+}
+
+func IsExitError(err error) bool {
+	_, isExitError := err.(*ExitError)
+	return isExitError
+}
+
+// runContainer starts a created container, waits for it and saves its logs to logPath
+func runContainer(ctx context.Context, containerRuntime misc.ContainerRuntime, containerID string, stage string, taskName string, logPath string) error {
+	// start this container
+	containerStartError := containerRuntime.ContainerStart(ctx, containerID)
+	if containerStartError != nil {
+		return misc.WrapRuntimeError(containerStartError)
+	}
+
+	// wait for container to be done running
+	exitCode, waitErr := containerRuntime.ContainerWait(ctx, containerID)
+	if waitErr != nil {
+		return misc.WrapRuntimeError(waitErr)
+	}
+
+	// save stdout and stderr next to the task files
+	logs := bytes.Buffer{}
+	logsError := containerRuntime.ContainerLogs(ctx, containerID, &logs)
+	if logsError != nil {
+		log.Println(fmt.Sprintf("WARN: Cannot read the logs of %s of %s: %s", stage, taskName, logsError.Error()))
+	}
+
+	writeError := ioutil.WriteFile(logPath, logs.Bytes(), os.FileMode(0666))
+	if writeError != nil {
+		return writeError
+	}
+
+	// a crashed tool is a failure, not an empty result
+	if exitCode != 0 {
+		return &ExitError{
+			Stage:       stage,
+			TaskName:    taskName,
+			ContainerID: containerID,
+			ExitCode:    exitCode,
+			LogTail:     logTail(logs.String(), LOG_TAIL_LINES),
+		}
+	}
+
+	return nil
+}
+
+func logTail(logs string, numLines int) string {
+	lines := strings.Split(strings.TrimRight(logs, "\n"), "\n")
+	if len(lines) > numLines {
+		lines = lines[len(lines)-numLines:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,368 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"itextmine/misc"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gammazero/workerpool"
+)
+
+// ExecuteOptions controls how the tasks of a tool are executed
+type ExecuteOptions struct {
+	WorkDir          string
+	NumParallelTasks int
+
+	// Resume skips the stages that the checkpoint of a previous run recorded as done
+	Resume bool
+
+	// RetryPolicy retries the stages failing with transient errors
+	RetryPolicy RetryPolicy
+
+	// StageTimeouts stops the stage containers running for too long
+	StageTimeouts StageTimeouts
+
+	// RunTimeout is the deadline of the whole run, no deadline when 0
+	RunTimeout time.Duration
+
+	// RunID prefixes the names of the containers and the network of the run and labels them, so that concurrent runs on
+	// a host do not collide and the run only cleans up its own containers. The names are not prefixed when empty.
+	RunID string
+
+	// RunSubnet moves the network of the tool to a subnet picked by the run id and the sidecars to the same host in it,
+	// so that concurrent runs do not ask for the same addresses. The subnet and addresses of the manifest are kept
+	// when false, e.g. for tools whose images expect the sidecar at its address.
+	RunSubnet bool
+
+	// Aligner overrides the aligner of the align steps, ALIGNER_CONTAINER or ALIGNER_NATIVE. The manifest decides when empty.
+	Aligner string
+
+	// Context stops the run when it is cancelled, e.g. on Ctrl-C. The containers are removed and the interrupted stages
+	// stay pending in the checkpoint. context.Background() when nil.
+	Context context.Context
+
+	// Stages only executes these stages, on the tasks whose earlier stages the checkpoint records as done. All the
+	// stages when empty.
+	Stages []string
+}
+
+// validRunID are the run ids that are valid in container and network names
+var validRunID = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// toolForRun looks up the tool, aligns with the aligner chosen for the run and names its containers after the run
+func toolForRun(toolName string, aligner string, runID string, runSubnet bool) (Tool, error) {
+	tool, toolError := GetTool(toolName)
+	if toolError != nil {
+		return nil, toolError
+	}
+
+	if len(aligner) > 0 {
+		if aligner != ALIGNER_CONTAINER && aligner != ALIGNER_NATIVE {
+			return nil, errors.New(fmt.Sprintf("Unknown aligner %s", aligner))
+		}
+		if alignerTool, isAlignerTool := tool.(alignerTool); isAlignerTool {
+			tool = alignerTool.withAligner(aligner)
+		}
+	}
+
+	if len(runID) > 0 {
+		if validRunID.MatchString(runID) == false {
+			return nil, errors.New(fmt.Sprintf("Invalid run id %s, use letters, digits, _, . and -", runID))
+		}
+		if runScopedTool, isRunScopedTool := tool.(runScopedTool); isRunScopedTool {
+			tool = runScopedTool.withRunID(runID, runSubnet)
+		}
+	}
+
+	return tool, nil
+}
+
+func Execute(containerRuntime misc.ContainerRuntime, toolName string, options ExecuteOptions) error {
+	workDir := options.WorkDir
+	numParallelTasks := options.NumParallelTasks
+
+	// look up the tool in the registry
+	tool, toolError := toolForRun(toolName, options.Aligner, options.RunID, options.RunSubnet)
+	if toolError != nil {
+		return toolError
+	}
+
+	// check the selected stages
+	for _, stage := range options.Stages {
+		if misc.StringInSlice(stage, tool.Stages()) == false {
+			return errors.New(fmt.Sprintf("Unknown %s stage %s", tool.Name(), stage))
+		}
+	}
+
+	// load the checkpoint of the previous run, or start a new one
+	checkpointPath := CheckpointPath(workDir, tool.Name())
+	checkpoint := NewCheckpoint(checkpointPath, tool.Name())
+	if options.Resume || len(options.Stages) > 0 {
+		// the selected stages run on the tasks that the previous run got far enough with
+		log.Println(fmt.Sprintf("Resuming from checkpoint %s", checkpointPath))
+		loadedCheckpoint, checkpointError := LoadCheckpoint(checkpointPath, tool.Name())
+		if checkpointError != nil {
+			return checkpointError
+		}
+		checkpoint = loadedCheckpoint
+	}
+
+	checkpointSaveError := checkpoint.Save()
+	if checkpointSaveError != nil {
+		return checkpointSaveError
+	}
+
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if options.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.RunTimeout)
+		defer cancel()
+	}
+
+	log.Println("Cleaning up docker env from previous run")
+	// cleanup from previous run
+	cleanupError := cleanUpTool(ctx, containerRuntime, tool)
+	if cleanupError != nil {
+		return interruptedRun(ctx, tool, cleanupError)
+	}
+
+	// start the sidecar services if the tool needs them, the align stages do without
+	if sidecarTool, isSidecarTool := tool.(SidecarTool); isSidecarTool && onlyAlignStages(options.Stages) == false {
+		// remove the services when we are done, also after the run deadline or a setup that was interrupted
+		defer sidecarTool.Teardown(context.Background(), containerRuntime)
+
+		setupError := sidecarTool.Setup(ctx, containerRuntime)
+		if setupError != nil {
+			return interruptedRun(ctx, tool, setupError)
+		}
+	}
+
+	// pull the docker images
+	for _, imageName := range tool.Images() {
+		pullError := misc.PullImage(ctx, containerRuntime, imageName)
+		if pullError != nil {
+			return interruptedRun(ctx, tool, pullError)
+		}
+	}
+
+	// get a list of all the tasks
+	toolWorkDirPath := path.Join(workDir, tool.Name())
+	log.Println(fmt.Sprintf("Generating tasks from : %s ", toolWorkDirPath))
+	tasks, tasksError := misc.GetSubDirNames(toolWorkDirPath)
+	if tasksError != nil {
+		return tasksError
+	}
+	misc.SortTaskNames(*tasks)
+
+	// create a worker pool and start the execution
+	wp := workerpool.New(numParallelTasks)
+
+	// number of tasks
+	stages := tool.Stages()
+	num_tasks := len(*tasks) * len(stages) // every stage of a task counts towards the progress
+	log.Println(fmt.Sprintf("Generated %d tasks", num_tasks))
+
+	// make a buffered channel to receive errors in go routine
+	errorChan := make(chan TaskFailure, num_tasks*2)
+
+	// make a buffered channel to receive progress
+	progressChan := make(chan bool, num_tasks)
+
+	// make a done channel to signal work completion
+	terminateChan := make(chan bool)
+
+	// start a goroutine to handle the messages from worker pool
+	go HandleProgress(progressChan, terminateChan, num_tasks)
+
+	log.Println(fmt.Sprintf("Starting the pool with %d workers", numParallelTasks))
+
+	for _, task := range *tasks {
+		taskCopy := task
+		wp.Submit(func() {
+			// once a stage runs again, the stages that depend on it have to run again as well
+			rerun := false
+
+			for stageIndex, stage := range stages {
+				// stages that are not selected only have to be done for the selected ones to run
+				if len(options.Stages) > 0 && misc.StringInSlice(stage, options.Stages) == false {
+					if checkpoint.IsDone(taskCopy, stage) {
+						progressChan <- true
+						continue
+					}
+
+					for remaining := stageIndex; remaining < len(stages); remaining++ {
+						progressChan <- true
+					}
+					break
+				}
+
+				if rerun == false && options.Resume && checkpoint.IsDone(taskCopy, stage) {
+					progressChan <- true
+					continue
+				}
+				rerun = true
+
+				// execute the stage container
+				stageStatus := STAGE_DONE
+				stageError := executeStageWithRetries(ctx, containerRuntime, tool, stage, taskCopy, workDir, options.RetryPolicy, options.StageTimeouts)
+
+				// interrupted stages stay pending, a resumed run executes them again
+				if IsInterruptedError(stageError) {
+					for remaining := stageIndex; remaining < len(stages); remaining++ {
+						progressChan <- true
+					}
+					break
+				}
+
+				if stageError != nil {
+					log.Println(fmt.Sprintf("ERROR: %s", stageError.Error()))
+					errorChan <- NewTaskFailure(taskCopy, stage, stageError)
+					stageStatus = STAGE_FAILED
+					if IsTimeoutError(stageError) {
+						stageStatus = STAGE_TIMED_OUT
+					}
+				}
+
+				// record the stage
+				checkpointError := checkpoint.Mark(taskCopy, stage, stageStatus)
+				if checkpointError != nil {
+					log.Println(fmt.Sprintf("ERROR: %s", checkpointError.Error()))
+					errorChan <- NewTaskFailure(taskCopy, stage, checkpointError)
+				}
+
+				progressChan <- true
+
+				// the later stages depend on the failed one
+				if stageStatus != STAGE_DONE {
+					for remaining := stageIndex + 1; remaining < len(stages); remaining++ {
+						progressChan <- true
+					}
+					break
+				}
+			}
+		})
+	}
+
+	wp.StopWait()
+
+	// close the channels channel
+	close(errorChan)
+	close(progressChan)
+
+	// get the errors from error channel
+	failures := make([]TaskFailure, 0)
+	for failure := range errorChan {
+		failures = append(failures, failure)
+	}
+
+	// send a message on done channel to quit the goroutine
+	terminateChan <- true
+
+	// check if we had any errors
+	var failuresError *ExecuteError
+	if len(failures) > 0 {
+		failuresError = summarizeFailures(tool.Name(), failures)
+	}
+
+	// the checkpoint of an interrupted run is saved once the stages in progress are stopped
+	if interrupted(ctx) {
+		checkpointSaveError := checkpoint.Save()
+		if checkpointSaveError != nil {
+			return checkpointSaveError
+		}
+		log.Println(fmt.Sprintf("Saved the checkpoint %s", checkpointPath))
+
+		// the tasks that failed before the interrupt are still reported
+		return &InterruptedError{Tool: tool.Name(), Failures: failuresError}
+	}
+
+	// a nil *ExecuteError is not a nil error
+	if failuresError == nil {
+		return nil
+	}
+	return failuresError
+}
+
+// interruptedRun replaces the error of a run that was interrupted before its tasks were executed
+func interruptedRun(ctx context.Context, tool Tool, err error) error {
+	if interrupted(ctx) {
+		return &InterruptedError{Tool: tool.Name()}
+	}
+	return err
+}
+
+func summarizeFailures(toolName string, failures []TaskFailure) *ExecuteError {
+	// order by task so that the summary is easy to read
+	sort.SliceStable(failures, func(i, j int) bool {
+		return misc.LessTaskName(failures[i].TaskName, failures[j].TaskName)
+	})
+
+	log.Println(fmt.Sprintf("Failed stages: %d", len(failures)))
+	for _, failure := range failures {
+		if failure.Status == STAGE_TIMED_OUT {
+			log.Println(fmt.Sprintf("TIMED OUT: %s %s: %s", failure.TaskName, failure.Stage, failure.Message))
+		} else {
+			log.Println(fmt.Sprintf("FAILED: %s %s: %s", failure.TaskName, failure.Stage, failure.Message))
+		}
+	}
+
+	return &ExecuteError{Tool: toolName, Failures: failures}
+}
+
+// Clean removes the containers and networks left over by a run of the tool, only those of the run when runID is given
+func Clean(containerRuntime misc.ContainerRuntime, toolName string, runID string) error {
+	tool, toolError := toolForRun(toolName, "", runID, false)
+	if toolError != nil {
+		return toolError
+	}
+
+	ctx := context.Background()
+	cleanupError := cleanUpTool(ctx, containerRuntime, tool)
+	if cleanupError != nil {
+		return cleanupError
+	}
+
+	// the sidecars and the network
+	if sidecarTool, isSidecarTool := tool.(SidecarTool); isSidecarTool {
+		return sidecarTool.Teardown(ctx, containerRuntime)
+	}
+
+	return nil
+}
+
+// onlyAlignStages is true when stages are selected and all of them are align stages
+func onlyAlignStages(stages []string) bool {
+	for _, stage := range stages {
+		if strings.HasSuffix(stage, ALIGN_STAGE_SUFFIX) == false {
+			return false
+		}
+	}
+	return len(stages) > 0
+}
+
+func cleanUpTool(ctx context.Context, containerRuntime misc.ContainerRuntime, tool Tool) error {
+	// remove the containers of this run, concurrent runs of the tool keep theirs
+	if runScopedTool, isRunScopedTool := tool.(runScopedTool); isRunScopedTool && len(runScopedTool.runLabels()) > 0 {
+		return misc.RemoveContainersByLabels(ctx, containerRuntime, runScopedTool.runLabels())
+	}
+
+	// remove dangling containers of this tool
+	for _, containerPattern := range tool.CleanupPatterns() {
+		danglingRemoveError := misc.RemoveContainer(ctx, containerRuntime, containerPattern)
+		if danglingRemoveError != nil {
+			return danglingRemoveError
+		}
+	}
+
+	return nil
+}
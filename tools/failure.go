@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// FAILURE_REPORT_NAME is the report written to the output dir when tasks fail
+const FAILURE_REPORT_NAME string = "failures.json"
+
+// TaskFailure is a stage of a task that ultimately failed, after its retries
+type TaskFailure struct {
+	TaskName    string `json:"task"`
+	Stage       string `json:"stage"`
+	Status      string `json:"status"`
+	ContainerID string `json:"containerId,omitempty"`
+	ExitCode    int64  `json:"exitCode,omitempty"`
+	Message     string `json:"message"`
+}
+
+func NewTaskFailure(taskName string, stage string, err error) TaskFailure {
+	failure := TaskFailure{
+		TaskName: taskName,
+		Stage:    stage,
+		Status:   STAGE_FAILED,
+		Message:  err.Error(),
+	}
+
+	if IsTimeoutError(err) {
+		failure.Status = STAGE_TIMED_OUT
+	} else if exitError, isExitError := err.(*ExitError); isExitError {
+		failure.ContainerID = exitError.ContainerID
+		failure.ExitCode = exitError.ExitCode
+	}
+
+	return failure
+}
+
+// ExecuteError collects all the failed stages of a run
+type ExecuteError struct {
+	Tool     string        `json:"tool"`
+	Failures []TaskFailure `json:"failures"`
+}
+
+func (executeError *ExecuteError) Error() string {
+	return fmt.Sprintf("%d tasks failed: %s. First error: %s", len(executeError.FailedTasks()), strings.Join(executeError.FailedTasks(), ", "), executeError.Failures[0].Message)
+}
+
+// FailedTasks returns the names of the failed tasks, in the order of the failures
+func (executeError *ExecuteError) FailedTasks() []string {
+	failedTasks := make([]string, 0)
+	for _, failure := range executeError.Failures {
+		if len(failedTasks) == 0 || failedTasks[len(failedTasks)-1] != failure.TaskName {
+			failedTasks = append(failedTasks, failure.TaskName)
+		}
+	}
+	return failedTasks
+}
+
+// WriteReport writes the failures as json, for scripts that resubmit the failed tasks
+func (executeError *ExecuteError) WriteReport(filePath string) error {
+	reportBytes, marshalError := json.MarshalIndent(executeError, "", "  ")
+	if marshalError != nil {
+		return marshalError
+	}
+
+	return ioutil.WriteFile(filePath, reportBytes, os.FileMode(0666))
+}
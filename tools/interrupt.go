@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+// InterruptedError is returned for a run, or a stage of a task, that was stopped by cancelling the context of the
+// run, e.g. on Ctrl-C. The interrupted stages stay pending in the checkpoint.
+type InterruptedError struct {
+	Tool     string
+	Stage    string
+	TaskName string
+
+	// Failures of the tasks that failed before the run was interrupted, nil when none did
+	Failures *ExecuteError
+}
+
+func (interruptedError *InterruptedError) Error() string {
+	if len(interruptedError.Stage) > 0 {
+		return fmt.Sprintf("%s of %s was interrupted", interruptedError.Stage, interruptedError.TaskName)
+	} else if interruptedError.Failures != nil {
+		return fmt.Sprintf("Run of %s was interrupted after %d tasks failed, continue it with --resume", interruptedError.Tool, len(interruptedError.Failures.FailedTasks()))
+	}
+	return fmt.Sprintf("Run of %s was interrupted, continue it with --resume", interruptedError.Tool)
+}
+
+func IsInterruptedError(err error) bool {
+	_, isInterruptedError := err.(*InterruptedError)
+	return isInterruptedError
+}
+
+// interrupted is true once the run was cancelled, rather than stopped by its deadline
+func interrupted(ctx context.Context) bool {
+	return ctx.Err() == context.Canceled
+}
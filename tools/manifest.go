@@ -0,0 +1,226 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ToolManifest declares a container based tool so that it can be run without writing Go code
+type ToolManifest struct {
+	Name     string            `yaml:"name" json:"name"`
+	Network  *NetworkManifest  `yaml:"network" json:"network"`
+	Sidecars []SidecarManifest `yaml:"sidecars" json:"sidecars"`
+	Stages   []StageManifest   `yaml:"stages" json:"stages"`
+	Reduce   []ReduceOutput    `yaml:"reduce" json:"reduce"`
+
+	// Cleanup are extra patterns of left over containers, regular expressions matched against /name, e.g. ^/mytool-cache
+	Cleanup []string `yaml:"cleanup" json:"cleanup"`
+}
+
+// NetworkManifest is a bridge network created for the tool before the tasks are executed
+type NetworkManifest struct {
+	Name   string `yaml:"name" json:"name"`
+	Subnet string `yaml:"subnet" json:"subnet"`
+}
+
+// SidecarManifest is a service container started on the tool network before the tasks are executed
+type SidecarManifest struct {
+	Name         string `yaml:"name" json:"name"`
+	Image        string `yaml:"image" json:"image"`
+	IPAddress    string `yaml:"ipAddress" json:"ipAddress"`
+	StartupDelay string `yaml:"startupDelay" json:"startupDelay"`
+
+	// Alias is the name of the sidecar on the network when its container name is prefixed with a run id
+	Alias string `yaml:"-" json:"-"`
+}
+
+// StageManifest is a container executed once for every task
+type StageManifest struct {
+	Name            string          `yaml:"name" json:"name"`
+	Image           string          `yaml:"image" json:"image"`
+	ContainerPrefix string          `yaml:"containerPrefix" json:"containerPrefix"`
+	Network         bool            `yaml:"network" json:"network"`
+	Mounts          []MountManifest `yaml:"mounts" json:"mounts"`
+	Output          string          `yaml:"output" json:"output"`
+	Align           *AlignManifest  `yaml:"align" json:"align"`
+}
+
+// MountManifest binds a file of the task folder into the stage container
+type MountManifest struct {
+	Source   string `yaml:"source" json:"source"`
+	Target   string `yaml:"target" json:"target"`
+	ReadOnly bool   `yaml:"readOnly" json:"readOnly"`
+	Create   bool   `yaml:"create" json:"create"`
+}
+
+// AlignManifest aligns the stage output back to a task input once the stage is done.
+// Aligner is ALIGNER_CONTAINER (the default) or ALIGNER_NATIVE, Image defaults to ALIGN_IMAGE_NAME.
+type AlignManifest struct {
+	Name     string `yaml:"name" json:"name"`
+	Original string `yaml:"original" json:"original"`
+	Output   string `yaml:"output" json:"output"`
+	Aligner  string `yaml:"aligner" json:"aligner"`
+	Image    string `yaml:"image" json:"image"`
+}
+
+func LoadManifest(manifestPath string) (*ToolManifest, error) {
+	manifestBytes, readError := ioutil.ReadFile(manifestPath)
+	if readError != nil {
+		return nil, readError
+	}
+
+	// decode based on the file extension
+	manifest := ToolManifest{}
+	switch strings.ToLower(path.Ext(manifestPath)) {
+	case ".yaml", ".yml":
+		yamlError := yaml.UnmarshalStrict(manifestBytes, &manifest)
+		if yamlError != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %s", manifestPath, yamlError.Error()))
+		}
+	case ".json":
+		jsonError := json.Unmarshal(manifestBytes, &manifest)
+		if jsonError != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %s", manifestPath, jsonError.Error()))
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("%s is not a yaml or json manifest", manifestPath))
+	}
+
+	validateError := manifest.Validate()
+	if validateError != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", manifestPath, validateError.Error()))
+	}
+
+	return &manifest, nil
+}
+
+func LoadManifestDir(manifestDir string) error {
+	manifestPaths := make([]string, 0)
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, globError := filepath.Glob(path.Join(manifestDir, pattern))
+		if globError != nil {
+			return globError
+		}
+		manifestPaths = append(manifestPaths, matches...)
+	}
+
+	// load and register every manifest in the dir
+	for _, manifestPath := range manifestPaths {
+		manifest, manifestError := LoadManifest(manifestPath)
+		if manifestError != nil {
+			return manifestError
+		}
+
+		log.Println(fmt.Sprintf("Registering tool %s from %s", manifest.Name, manifestPath))
+		registerError := RegisterTool(NewManifestTool(*manifest))
+		if registerError != nil {
+			return registerError
+		}
+	}
+
+	return nil
+}
+
+func (manifest *ToolManifest) Validate() error {
+	if len(manifest.Name) == 0 {
+		return errors.New("Tool name cannot be empty")
+	}
+
+	if len(manifest.Stages) == 0 {
+		return errors.New(fmt.Sprintf("Tool %s has no stages", manifest.Name))
+	}
+
+	// check the network
+	if manifest.Network != nil && len(manifest.Network.Name) == 0 {
+		return errors.New(fmt.Sprintf("Network name of tool %s cannot be empty", manifest.Name))
+	}
+
+	// check the sidecars
+	for _, sidecar := range manifest.Sidecars {
+		if len(sidecar.Name) == 0 || len(sidecar.Image) == 0 {
+			return errors.New(fmt.Sprintf("Sidecars of tool %s need a name and an image", manifest.Name))
+		}
+
+		if len(sidecar.IPAddress) > 0 && manifest.Network == nil {
+			return errors.New(fmt.Sprintf("Sidecar %s has an ip address but tool %s has no network", sidecar.Name, manifest.Name))
+		}
+
+		if len(sidecar.StartupDelay) > 0 {
+			_, durationError := time.ParseDuration(sidecar.StartupDelay)
+			if durationError != nil {
+				return errors.New(fmt.Sprintf("Invalid startup delay of sidecar %s: %s", sidecar.Name, durationError.Error()))
+			}
+		}
+	}
+
+	// check the stages
+	stageNames := make([]string, 0)
+	for _, stage := range manifest.Stages {
+		if len(stage.Name) == 0 || len(stage.Image) == 0 {
+			return errors.New(fmt.Sprintf("Stages of tool %s need a name and an image", manifest.Name))
+		}
+
+		// align steps add a stage of their own
+		for _, stageName := range stageNames {
+			if stageName == stage.Name || (stage.Align != nil && stageName == stage.Name+ALIGN_STAGE_SUFFIX) {
+				return errors.New(fmt.Sprintf("Stage %s of tool %s is declared twice", stage.Name, manifest.Name))
+			}
+		}
+		stageNames = append(stageNames, stage.Name)
+		if stage.Align != nil {
+			stageNames = append(stageNames, stage.Name+ALIGN_STAGE_SUFFIX)
+		}
+
+		if stage.Network && manifest.Network == nil {
+			return errors.New(fmt.Sprintf("Stage %s needs a network but tool %s has no network", stage.Name, manifest.Name))
+		}
+
+		// mounts are relative to the task folder
+		for _, mount := range stage.Mounts {
+			if len(mount.Source) == 0 || len(mount.Target) == 0 {
+				return errors.New(fmt.Sprintf("Mounts of stage %s need a source and a target", stage.Name))
+			}
+
+			if isTaskFile(mount.Source) == false {
+				return errors.New(fmt.Sprintf("Mount source %s of stage %s is not a file in the task folder", mount.Source, stage.Name))
+			}
+		}
+
+		if stage.Align != nil {
+			if len(stage.Output) == 0 {
+				return errors.New(fmt.Sprintf("Stage %s aligns its output but has no output", stage.Name))
+			}
+
+			if isTaskFile(stage.Align.Output) == false {
+				return errors.New(fmt.Sprintf("Align output %s of stage %s is not a file in the task folder", stage.Align.Output, stage.Name))
+			}
+
+			if len(stage.Align.Aligner) > 0 && stage.Align.Aligner != ALIGNER_CONTAINER && stage.Align.Aligner != ALIGNER_NATIVE {
+				return errors.New(fmt.Sprintf("Unknown aligner %s of stage %s", stage.Align.Aligner, stage.Name))
+			}
+		}
+	}
+
+	// check the reduce outputs
+	for _, reduceOutput := range manifest.Reduce {
+		if len(reduceOutput.Name) == 0 || len(reduceOutput.Kind) == 0 || isTaskFile(reduceOutput.TaskFile) == false {
+			return errors.New(fmt.Sprintf("Reduce outputs of tool %s need a name, a kind and a task file", manifest.Name))
+		}
+	}
+
+	return nil
+}
+
+func isTaskFile(fileName string) bool {
+	// task files cannot point outside of the task folder
+	return len(fileName) > 0 && path.Base(fileName) == fileName && fileName != ".." && fileName != "."
+}
@@ -0,0 +1,429 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"itextmine/constants"
+	"itextmine/misc"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const ALIGN_IMAGE_NAME string = "itextmine/align"
+
+// ALIGN_STAGE_SUFFIX names the align stage that follows a stage with an align step
+const ALIGN_STAGE_SUFFIX string = "-align"
+
+// manifestTool runs the stages declared in a ToolManifest
+type manifestTool struct {
+	manifest ToolManifest
+
+	// runID labels the containers and networks of the run, their names are prefixed with it by withRunID
+	runID string
+}
+
+func NewManifestTool(manifest ToolManifest) SidecarTool {
+	return &manifestTool{manifest: manifest}
+}
+
+func (tool *manifestTool) Name() string {
+	return tool.manifest.Name
+}
+
+func (tool *manifestTool) Images() []string {
+	images := make([]string, 0)
+	alignImages := make([]string, 0)
+	for _, stage := range tool.manifest.Stages {
+		if misc.StringInSlice(stage.Image, images) == false {
+			images = append(images, stage.Image)
+		}
+		if stage.Align != nil && stage.aligner() == ALIGNER_CONTAINER && misc.StringInSlice(stage.alignImage(), alignImages) == false {
+			alignImages = append(alignImages, stage.alignImage())
+		}
+	}
+
+	// the align images are pulled once after the stage images
+	for _, alignImage := range alignImages {
+		if misc.StringInSlice(alignImage, images) == false {
+			images = append(images, alignImage)
+		}
+	}
+
+	return images
+}
+
+// withAligner returns a copy of the tool that aligns every stage with the aligner
+func (tool *manifestTool) withAligner(aligner string) Tool {
+	manifest := tool.manifest
+	manifest.Stages = make([]StageManifest, 0, len(tool.manifest.Stages))
+	for _, stage := range tool.manifest.Stages {
+		if stage.Align != nil {
+			align := *stage.Align
+			align.Aligner = aligner
+			stage.Align = &align
+		}
+		manifest.Stages = append(manifest.Stages, stage)
+	}
+
+	return &manifestTool{manifest: manifest, runID: tool.runID}
+}
+
+// withRunID returns a copy of the tool whose container and network names are prefixed with the run id. With
+// moveSubnet the network gets a subnet of the run, the sidecars keep their host in it.
+func (tool *manifestTool) withRunID(runID string, moveSubnet bool) Tool {
+	manifest := tool.manifest
+	if tool.manifest.Network != nil {
+		network := *tool.manifest.Network
+		network.Name = runName(runID, network.Name)
+		if moveSubnet {
+			network.Subnet = runSubnet(runID, network.Subnet)
+		}
+		manifest.Network = &network
+	}
+
+	manifest.Sidecars = make([]SidecarManifest, 0, len(tool.manifest.Sidecars))
+	for _, sidecar := range tool.manifest.Sidecars {
+		// the stages still reach the sidecar by the name of the manifest
+		sidecar.Alias = sidecar.Name
+		sidecar.Name = runName(runID, sidecar.Name)
+		if tool.manifest.Network != nil {
+			sidecar.IPAddress = runAddress(sidecar.IPAddress, tool.manifest.Network.Subnet, manifest.Network.Subnet)
+		}
+		manifest.Sidecars = append(manifest.Sidecars, sidecar)
+	}
+
+	manifest.Stages = make([]StageManifest, 0, len(tool.manifest.Stages))
+	for _, stage := range tool.manifest.Stages {
+		stage.ContainerPrefix = runName(runID, stage.containerPrefix())
+		if stage.Align != nil {
+			align := *stage.Align
+			align.Name = runName(runID, stage.alignName())
+			stage.Align = &align
+		}
+		manifest.Stages = append(manifest.Stages, stage)
+	}
+
+	return &manifestTool{manifest: manifest, runID: runID}
+}
+
+// runLabels are the labels shared by the containers and networks of the run, nil without a run id
+func (tool *manifestTool) runLabels() map[string]string {
+	if len(tool.runID) == 0 {
+		return nil
+	}
+	return tool.labels("", "")
+}
+
+// labels of a container or network of the tool, the task and stage are left out when empty
+func (tool *manifestTool) labels(taskName string, stageName string) map[string]string {
+	labels := map[string]string{constants.LABEL_TOOL: tool.manifest.Name}
+	if len(tool.runID) > 0 {
+		labels[constants.LABEL_RUN_ID] = tool.runID
+	}
+	if len(taskName) > 0 {
+		labels[constants.LABEL_TASK] = taskName
+	}
+	if len(stageName) > 0 {
+		labels[constants.LABEL_STAGE] = stageName
+	}
+	return labels
+}
+
+func (tool *manifestTool) Stages() []string {
+	stageNames := make([]string, 0, len(tool.manifest.Stages))
+	for _, stage := range tool.manifest.Stages {
+		stageNames = append(stageNames, stage.Name)
+
+		// alignment runs as its own stage, so that it is recorded and retried on its own
+		if stage.Align != nil {
+			stageNames = append(stageNames, stage.Name+ALIGN_STAGE_SUFFIX)
+		}
+	}
+	return stageNames
+}
+
+func (tool *manifestTool) ReduceOutputs() []ReduceOutput {
+	return tool.manifest.Reduce
+}
+
+func (tool *manifestTool) CleanupPatterns() []string {
+	patterns := make([]string, 0)
+	for _, stage := range tool.manifest.Stages {
+		patterns = append(patterns, fmt.Sprintf("^/%s-task", stage.containerPrefix()))
+		if stage.Align != nil {
+			patterns = append(patterns, fmt.Sprintf("^/%s-align", stage.alignName()))
+		}
+	}
+
+	for _, sidecar := range tool.manifest.Sidecars {
+		patterns = append(patterns, fmt.Sprintf("^/%s$", sidecar.Name))
+	}
+
+	return append(patterns, tool.manifest.Cleanup...)
+}
+
+func (tool *manifestTool) Setup(ctx context.Context, containerRuntime misc.ContainerRuntime) error {
+	if tool.manifest.Network != nil {
+		// remove network left over from a previous run
+		networkRemoveError := tool.removeNetwork(ctx, containerRuntime)
+		if networkRemoveError != nil {
+			return networkRemoveError
+		}
+
+		// create the network
+		log.Println(fmt.Sprintf("Creating %s network", tool.manifest.Network.Name))
+		_, networkCreateError := containerRuntime.NetworkCreate(ctx, tool.manifest.Network.Name, tool.manifest.Network.Subnet, tool.labels("", ""))
+		if misc.IsSubnetInUseError(networkCreateError) {
+			return errors.New(fmt.Sprintf("Subnet %s of the %s network is in use by another network, give the run another --runid or the tool another subnet in the tools section of the config", tool.manifest.Network.Subnet, tool.manifest.Network.Name))
+		} else if networkCreateError != nil {
+			return networkCreateError
+		}
+	}
+
+	// start the sidecar containers
+	for _, sidecar := range tool.manifest.Sidecars {
+		log.Println(fmt.Sprintf("Creating %s container", sidecar.Name))
+		sidecarStartError := startSidecarContainer(ctx, containerRuntime, sidecar, tool.manifest.Network, tool.labels("", ""))
+		if sidecarStartError != nil {
+			return sidecarStartError
+		}
+	}
+
+	return nil
+}
+
+func (tool *manifestTool) Teardown(ctx context.Context, containerRuntime misc.ContainerRuntime) error {
+	// remove the sidecars before the network they are attached to
+	for _, sidecar := range tool.manifest.Sidecars {
+		containerRemoveError := misc.RemoveContainer(ctx, containerRuntime, fmt.Sprintf("^/%s$", sidecar.Name))
+		if containerRemoveError != nil {
+			return containerRemoveError
+		}
+	}
+
+	if tool.manifest.Network != nil {
+		return tool.removeNetwork(ctx, containerRuntime)
+	}
+
+	return nil
+}
+
+// removeNetwork removes the network of the run, or the networks matching the name of the network without a run id
+func (tool *manifestTool) removeNetwork(ctx context.Context, containerRuntime misc.ContainerRuntime) error {
+	if len(tool.runID) > 0 {
+		return misc.RemoveNetworksByLabels(ctx, containerRuntime, tool.runLabels())
+	}
+	return misc.RemoveNetwork(ctx, containerRuntime, fmt.Sprintf("^%s$", tool.manifest.Network.Name))
+}
+
+func (tool *manifestTool) ExecuteStage(ctx context.Context, containerRuntime misc.ContainerRuntime, stageName string, taskName string, workdir string) error {
+	taskDirAbsolutePath, taskDirPathError := filepath.Abs(path.Join(workdir, tool.manifest.Name, taskName))
+	if taskDirPathError != nil {
+		return taskDirPathError
+	}
+
+	// align stages follow the stage they align
+	if strings.HasSuffix(stageName, ALIGN_STAGE_SUFFIX) {
+		alignedStage, alignedStageFound := tool.stage(strings.TrimSuffix(stageName, ALIGN_STAGE_SUFFIX))
+		if alignedStageFound && alignedStage.Align != nil {
+			return executeAlignStage(ctx, containerRuntime, alignedStage, stageName, taskName, taskDirAbsolutePath, tool.labels(taskName, stageName))
+		}
+	}
+
+	stage, stageFound := tool.stage(stageName)
+	if stageFound == false {
+		return errors.New(fmt.Sprintf("Unknown %s stage %s", tool.manifest.Name, stageName))
+	}
+
+	// build the bind mounts
+	binds := make([]string, 0, len(stage.Mounts))
+	for _, mount := range stage.Mounts {
+		mountSourcePath := path.Join(taskDirAbsolutePath, mount.Source)
+
+		// output files have to exist before they can be mounted
+		if mount.Create {
+			touchError := misc.TouchFile(mountSourcePath)
+			if touchError != nil {
+				return touchError
+			}
+		}
+
+		bind := fmt.Sprintf("%s:%s", mountSourcePath, mount.Target)
+		if mount.ReadOnly {
+			bind = bind + ":ro"
+		}
+		binds = append(binds, bind)
+	}
+
+	// container spec
+	containerSpec := misc.ContainerSpec{
+		Name:   stage.containerName(taskName),
+		Image:  stage.Image,
+		Binds:  binds,
+		Labels: tool.labels(taskName, stageName),
+	}
+
+	// attach to the tool network
+	if stage.Network {
+		containerSpec.Network = tool.manifest.Network.Name
+	}
+
+	// create the container
+	containerID, containerCreateError := misc.CreateContainer(ctx, containerRuntime, containerSpec)
+	if containerCreateError != nil {
+		return containerCreateError
+	}
+
+	// remove the container when we are done, also when the stage ran out of time
+	defer containerRuntime.ContainerRemove(context.Background(), containerID)
+
+	// run the container and keep its logs with the task
+	runError := runContainer(ctx, containerRuntime, containerID, stageName, taskName, path.Join(taskDirAbsolutePath, stageName+".log"))
+	if runError != nil {
+		return runError
+	}
+
+	// the output of stages with an align step is checked by the align stage
+	if len(stage.Output) == 0 || stage.Align != nil {
+		return nil
+	}
+
+	// check the output
+	checkoutputErr := misc.CheckOutput(path.Join(taskDirAbsolutePath, stage.Output))
+	if checkoutputErr != nil {
+		// No output being present is not an an error. The tool might not find anything in this set of docs
+		log.Println(fmt.Sprintf("WARN: %s", checkoutputErr.Error()))
+	}
+
+	return nil
+}
+
+func executeAlignStage(ctx context.Context, containerRuntime misc.ContainerRuntime, stage StageManifest, stageName string, taskName string, taskDirAbsolutePath string, labels map[string]string) error {
+	// check the output
+	taskOutputAbsolutePath := path.Join(taskDirAbsolutePath, stage.Output)
+	checkoutputErr := misc.CheckOutput(taskOutputAbsolutePath)
+	if checkoutputErr != nil {
+		// No output being present is not an an error. The tool might not find anything in this set of docs
+		log.Println(fmt.Sprintf("WARN: %s", checkoutputErr.Error()))
+		return nil
+	}
+
+	// align in process, without a container
+	if stage.aligner() == ALIGNER_NATIVE {
+		return executeNativeAlign(taskName,
+			path.Join(taskDirAbsolutePath, stage.alignOriginal()),
+			taskOutputAbsolutePath,
+			path.Join(taskDirAbsolutePath, stage.Align.Output),
+			path.Join(taskDirAbsolutePath, stageName+".log"))
+	}
+
+	// run alignment
+	return ExecuteAlign(ctx, containerRuntime, taskName,
+		path.Join(taskDirAbsolutePath, stage.alignOriginal()),
+		taskOutputAbsolutePath,
+		path.Join(taskDirAbsolutePath, stage.Align.Output),
+		path.Join(taskDirAbsolutePath, stageName+".log"),
+		stage.alignName(),
+		stage.alignImage(),
+		labels)
+}
+
+func (tool *manifestTool) stage(stageName string) (StageManifest, bool) {
+	for _, stage := range tool.manifest.Stages {
+		if stage.Name == stageName {
+			return stage, true
+		}
+	}
+	return StageManifest{}, false
+}
+
+func (stage StageManifest) containerPrefix() string {
+	if len(stage.ContainerPrefix) > 0 {
+		return stage.ContainerPrefix
+	}
+	return stage.Name
+}
+
+func (stage StageManifest) containerName(taskName string) string {
+	return fmt.Sprintf("%s-%s", stage.containerPrefix(), taskName)
+}
+
+func (stage StageManifest) alignName() string {
+	if len(stage.Align.Name) > 0 {
+		return stage.Align.Name
+	}
+	return stage.Name
+}
+
+func (stage StageManifest) alignImage() string {
+	if len(stage.Align.Image) > 0 {
+		return stage.Align.Image
+	}
+	return ALIGN_IMAGE_NAME
+}
+
+func (stage StageManifest) aligner() string {
+	if len(stage.Align.Aligner) > 0 {
+		return stage.Align.Aligner
+	}
+	return ALIGNER_CONTAINER
+}
+
+func (stage StageManifest) alignOriginal() string {
+	if len(stage.Align.Original) > 0 {
+		return stage.Align.Original
+	}
+	return "input.json"
+}
+
+func startSidecarContainer(ctx context.Context, containerRuntime misc.ContainerRuntime, sidecar SidecarManifest, networkManifest *NetworkManifest, labels map[string]string) error {
+	// pull the image
+	pullError := misc.PullImage(ctx, containerRuntime, sidecar.Image)
+	if pullError != nil {
+		return pullError
+	}
+
+	// container spec
+	containerSpec := misc.ContainerSpec{
+		Name:   sidecar.Name,
+		Image:  sidecar.Image,
+		Labels: labels,
+	}
+
+	// attach the sidecar to the tool network
+	if networkManifest != nil {
+		containerSpec.Network = networkManifest.Name
+		containerSpec.IPAddress = sidecar.IPAddress
+		if len(sidecar.Alias) > 0 {
+			containerSpec.Aliases = []string{sidecar.Alias}
+		}
+	}
+
+	// create the container
+	containerID, containerCreateError := misc.CreateContainer(ctx, containerRuntime, containerSpec)
+	if containerCreateError != nil {
+		return containerCreateError
+	}
+
+	// start this container
+	containerStartError := containerRuntime.ContainerStart(ctx, containerID)
+	if containerStartError != nil {
+		return containerStartError
+	}
+
+	// give the service time to init, unless the run is stopped
+	if len(sidecar.StartupDelay) > 0 {
+		startupDelay, _ := time.ParseDuration(sidecar.StartupDelay)
+		select {
+		case <-time.After(startupDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
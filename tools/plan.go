@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"fmt"
+	"itextmine/misc"
+	"strings"
+)
+
+// RunPlan is what a run of the tool would pull and create, for a dry run
+type RunPlan struct {
+	Tool       string          `json:"tool"`
+	Stages     []string        `json:"stages"`
+	Images     []string        `json:"images"`
+	Networks   []string        `json:"networks"`
+	Sidecars   []string        `json:"sidecars"`
+	Containers []ContainerPlan `json:"containers"`
+}
+
+// ContainerPlan are the containers of a stage, one for every task from First to Last
+type ContainerPlan struct {
+	Stage string `json:"stage"`
+	Image string `json:"image"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+	Count int    `json:"count"`
+}
+
+// PlanRun lists the images, networks and containers of a run on taskCount tasks without touching the container runtime.
+// The networks and containers are only known for the tools declared by a manifest.
+func PlanRun(toolName string, aligner string, runID string, taskCount int) (*RunPlan, error) {
+	tool, toolError := toolForRun(toolName, aligner, runID, false)
+	if toolError != nil {
+		return nil, toolError
+	}
+
+	plan := RunPlan{
+		Tool:       tool.Name(),
+		Stages:     tool.Stages(),
+		Images:     tool.Images(),
+		Networks:   make([]string, 0),
+		Sidecars:   make([]string, 0),
+		Containers: make([]ContainerPlan, 0),
+	}
+
+	plannedTool, isManifestTool := tool.(*manifestTool)
+	if isManifestTool == false {
+		return &plan, nil
+	}
+
+	if plannedTool.manifest.Network != nil {
+		plan.Networks = append(plan.Networks, plannedTool.manifest.Network.Name)
+	}
+
+	// the sidecar images are pulled when the sidecars start
+	for _, sidecar := range plannedTool.manifest.Sidecars {
+		plan.Sidecars = append(plan.Sidecars, sidecar.Name)
+		if misc.StringInSlice(sidecar.Image, plan.Images) == false {
+			plan.Images = append(plan.Images, sidecar.Image)
+		}
+	}
+
+	if taskCount == 0 {
+		return &plan, nil
+	}
+
+	// the first and the last task folder
+	firstTask, lastTask := "task_0", fmt.Sprintf("task_%d", taskCount-1)
+	for _, stage := range plannedTool.manifest.Stages {
+		plan.Containers = append(plan.Containers, ContainerPlan{
+			Stage: stage.Name,
+			Image: stage.Image,
+			First: stage.containerName(firstTask),
+			Last:  stage.containerName(lastTask),
+			Count: taskCount,
+		})
+
+		// native alignment runs in process
+		if stage.Align != nil && stage.aligner() == ALIGNER_CONTAINER {
+			plan.Containers = append(plan.Containers, ContainerPlan{
+				Stage: stage.Name + ALIGN_STAGE_SUFFIX,
+				Image: stage.alignImage(),
+				First: alignContainerName(stage.alignName(), firstTask),
+				Last:  alignContainerName(stage.alignName(), lastTask),
+				Count: taskCount,
+			})
+		}
+	}
+
+	return &plan, nil
+}
+
+func (plan *RunPlan) String() string {
+	lines := []string{
+		fmt.Sprintf("Stages of %s: %s", plan.Tool, strings.Join(plan.Stages, ", ")),
+		fmt.Sprintf("Images to pull: %s", strings.Join(plan.Images, ", ")),
+	}
+
+	if len(plan.Networks) > 0 {
+		lines = append(lines, fmt.Sprintf("Networks to create: %s", strings.Join(plan.Networks, ", ")))
+	}
+	if len(plan.Sidecars) > 0 {
+		lines = append(lines, fmt.Sprintf("Sidecar containers to start: %s", strings.Join(plan.Sidecars, ", ")))
+	}
+
+	if len(plan.Containers) > 0 {
+		lines = append(lines, "Containers to create:")
+	}
+	for _, containerPlan := range plan.Containers {
+		lines = append(lines, fmt.Sprintf("  %s: %d containers %s ... %s of %s", containerPlan.Stage, containerPlan.Count, containerPlan.First, containerPlan.Last, containerPlan.Image))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,420 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"itextmine/misc"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// MAX_REPORTED_CORRUPT_LINES limits the corrupt lines listed in a reduce error
+const MAX_REPORTED_CORRUPT_LINES int = 10
+
+// ReduceOptions controls how the task outputs are reduced
+type ReduceOptions struct {
+	// SortByDocId orders the records by docId instead of the input doc order
+	SortByDocId bool
+
+	// Compression of the reduced outputs, misc.COMPRESSION_GZIP or misc.COMPRESSION_ZSTD. Plain json when empty.
+	Compression string
+}
+
+// CorruptLine is a line of a task file that is not valid json
+type CorruptLine struct {
+	TaskName   string
+	TaskFile   string
+	LineNumber int
+}
+
+// CorruptLinesError is returned when task files hold lines that are not valid json.
+// The corrupt lines are left out of the reduced output.
+type CorruptLinesError struct {
+	OutputFile   string
+	CorruptLines []CorruptLine
+}
+
+func (corruptLinesError *CorruptLinesError) Error() string {
+	locations := make([]string, 0, MAX_REPORTED_CORRUPT_LINES)
+	for _, corruptLine := range corruptLinesError.CorruptLines {
+		if len(locations) == MAX_REPORTED_CORRUPT_LINES {
+			locations = append(locations, "...")
+			break
+		}
+		locations = append(locations, fmt.Sprintf("%s/%s:%d", corruptLine.TaskName, corruptLine.TaskFile, corruptLine.LineNumber))
+	}
+
+	return fmt.Sprintf("%d corrupt lines left out of %s: %s", len(corruptLinesError.CorruptLines), corruptLinesError.OutputFile, strings.Join(locations, ", "))
+}
+
+// ReduceError collects the errors of the reduce outputs of a tool, the outputs without errors are written
+type ReduceError struct {
+	Outputs int
+	Errors  []error
+}
+
+func (reduceError *ReduceError) Error() string {
+	messages := make([]string, 0, len(reduceError.Errors))
+	for _, err := range reduceError.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return fmt.Sprintf("%d of %d reduce outputs have errors, %d corrupt lines left out: %s", len(reduceError.Errors), reduceError.Outputs, len(reduceError.CorruptLines()), strings.Join(messages, "; "))
+}
+
+// CorruptLines are the corrupt lines left out of all the reduce outputs
+func (reduceError *ReduceError) CorruptLines() []CorruptLine {
+	corruptLines := make([]CorruptLine, 0)
+	for _, err := range reduceError.Errors {
+		if corruptLinesError, isCorruptLinesError := err.(*CorruptLinesError); isCorruptLinesError {
+			corruptLines = append(corruptLines, corruptLinesError.CorruptLines...)
+		}
+	}
+	return corruptLines
+}
+
+// reducedLine is a record held back to be sorted by docId or by input doc order
+type reducedLine struct {
+	docId      string
+	inputIndex int
+	line       []byte
+}
+
+// taskInputOrder gives the position among all the input lines of the docIds of a task
+type taskInputOrder struct {
+	firstIndex int
+	docIndexes map[string]int
+}
+
+// ReduceOutputPath is the file a reduce output is written to, <Name>.<collection>.<Kind>.json with the extension of the compression
+func ReduceOutputPath(outputDir string, collectionType string, reduceOutput ReduceOutput, options ReduceOptions) string {
+	outputFilePath := path.Join(outputDir, fmt.Sprintf("%s.%s.%s.json", reduceOutput.Name, collectionType, reduceOutput.Kind))
+	if len(options.Compression) > 0 {
+		outputFilePath = fmt.Sprintf("%s.%s", outputFilePath, options.Compression)
+	}
+	return outputFilePath
+}
+
+func reduceTaskFile(toolWorkDir string, toolOutputDir string, collectionType string, reduceOutput ReduceOutput, inputOrder map[string]taskInputOrder, options ReduceOptions) error {
+	outputFilePath := ReduceOutputPath(toolOutputDir, collectionType, reduceOutput, options)
+
+	log.Println(fmt.Sprintf("Reducing %s %s results to : %s", reduceOutput.Name, reduceOutput.Kind, outputFilePath))
+
+	taskNames, taskNamesError := taskDirNames(toolWorkDir)
+	if taskNamesError != nil {
+		return taskNamesError
+	}
+
+	outputFile, outputFileError := misc.CreateOutputFile(outputFilePath, options.Compression)
+	if outputFileError != nil {
+		return outputFileError
+	}
+	defer outputFile.Close()
+
+	// stream the task files into the collection file, in the order of the input doc
+	writer := bufio.NewWriter(outputFile)
+	linesWritten := 0
+	writeLine := func(taskName string, line []byte) error {
+		linesWritten = linesWritten + 1
+		_, writeError := writer.Write(append(line, '\n'))
+		return writeError
+	}
+
+	// records sorted by docId are held back until all the tasks are read
+	reducedLines := make([]reducedLine, 0)
+	if options.SortByDocId {
+		writeLine = func(taskName string, line []byte) error {
+			docId, docIdError := lineDocId(line)
+			if docIdError != nil {
+				return errors.New(fmt.Sprintf("%s/%s: %s", taskName, reduceOutput.TaskFile, docIdError.Error()))
+			}
+			reducedLines = append(reducedLines, reducedLine{docId: docId, line: line})
+			return nil
+		}
+	} else if inputOrder != nil {
+		// records of a group appended to an earlier task are put back in the input doc order,
+		// records of docIds that are not in the task input follow the record before them
+		lineTaskName, lineIndex := "", 0
+		writeLine = func(taskName string, line []byte) error {
+			taskOrder := inputOrder[taskName]
+			if taskName != lineTaskName {
+				lineTaskName, lineIndex = taskName, taskOrder.firstIndex
+			}
+
+			docId, docIdError := lineDocId(line)
+			if inputIndex, isInput := taskOrder.docIndexes[docId]; docIdError == nil && isInput {
+				lineIndex = inputIndex
+			}
+			reducedLines = append(reducedLines, reducedLine{inputIndex: lineIndex, line: line})
+			return nil
+		}
+	}
+
+	corruptLines := make([]CorruptLine, 0)
+	for _, taskName := range taskNames {
+		taskCorruptLines, readError := readTaskFile(toolWorkDir, taskName, reduceOutput.TaskFile, writeLine)
+		if readError != nil {
+			return readError
+		}
+		corruptLines = append(corruptLines, taskCorruptLines...)
+	}
+
+	if options.SortByDocId || inputOrder != nil {
+		// records of the same doc keep the input doc order
+		sort.SliceStable(reducedLines, func(i, j int) bool {
+			if options.SortByDocId {
+				return lessDocId(reducedLines[i].docId, reducedLines[j].docId)
+			}
+			return reducedLines[i].inputIndex < reducedLines[j].inputIndex
+		})
+
+		for _, reducedLine := range reducedLines {
+			linesWritten = linesWritten + 1
+			_, writeError := writer.Write(append(reducedLine.line, '\n'))
+			if writeError != nil {
+				return writeError
+			}
+		}
+	}
+
+	flushError := writer.Flush()
+	if flushError != nil {
+		return flushError
+	}
+
+	closeError := outputFile.Close()
+	if closeError != nil {
+		return closeError
+	}
+
+	if len(corruptLines) > 0 {
+		return &CorruptLinesError{OutputFile: outputFilePath, CorruptLines: corruptLines}
+	}
+
+	// check reduce output, the size of a compressed file does not tell whether it is empty
+	if linesWritten == 0 {
+		return errors.New(fmt.Sprintf("%s is empty", outputFilePath))
+	}
+
+	return nil
+}
+
+func readTaskFile(toolWorkDir string, taskName string, taskFile string, handleLine func(taskName string, line []byte) error) ([]CorruptLine, error) {
+	corruptLines := make([]CorruptLine, 0)
+
+	taskFilePath := path.Join(toolWorkDir, taskName, taskFile)
+	readError := readLines(taskFilePath, func(lineNumber int, line []byte) error {
+		if json.Valid(line) == false {
+			log.Println(fmt.Sprintf("ERROR: Line %d of %s is not valid json", lineNumber, taskFilePath))
+			corruptLines = append(corruptLines, CorruptLine{TaskName: taskName, TaskFile: taskFile, LineNumber: lineNumber})
+			return nil
+		}
+		return handleLine(taskName, line)
+	})
+	if os.IsNotExist(readError) {
+		// tasks without results are not aligned
+		log.Println(fmt.Sprintf("WARN: %s does not exist", taskFilePath))
+		return corruptLines, nil
+	} else if readError != nil {
+		return nil, readError
+	}
+
+	return corruptLines, nil
+}
+
+func taskDirNames(toolWorkDir string) ([]string, error) {
+	fileInfos, readDirError := ioutil.ReadDir(toolWorkDir)
+	if readDirError != nil {
+		return nil, readDirError
+	}
+
+	taskNames := make([]string, 0, len(fileInfos))
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() {
+			taskNames = append(taskNames, fileInfo.Name())
+		}
+	}
+	misc.SortTaskNames(taskNames)
+
+	return taskNames, nil
+}
+
+func lineDocId(line []byte) (string, error) {
+	record := struct {
+		DocId interface{} `json:"docId"`
+	}{}
+
+	unmarshalError := json.Unmarshal(line, &record)
+	if unmarshalError != nil {
+		return "", unmarshalError
+	}
+
+	// the docIds are read like the validation of the input docs reads them
+	return misc.DocIdString(record.DocId)
+}
+
+// lessDocId compares the runs of digits of the docIds as numbers and the other runs as text,
+// so that pmid 9 comes before 10 and PMC1-2 before PMC1-10
+func lessDocId(docId string, otherDocId string) bool {
+	runs, otherRuns := docIdRuns(docId), docIdRuns(otherDocId)
+	for runIndex := 0; runIndex < len(runs) && runIndex < len(otherRuns); runIndex++ {
+		if runs[runIndex] != otherRuns[runIndex] {
+			return lessDocIdRun(runs[runIndex], otherRuns[runIndex])
+		}
+	}
+	return len(runs) < len(otherRuns)
+}
+
+func docIdRuns(docId string) []string {
+	runs := make([]string, 0)
+	runStart := 0
+	for charIndex := 1; charIndex <= len(docId); charIndex++ {
+		if charIndex == len(docId) || isDigit(docId[charIndex]) != isDigit(docId[runStart]) {
+			runs = append(runs, docId[runStart:charIndex])
+			runStart = charIndex
+		}
+	}
+	return runs
+}
+
+func lessDocIdRun(run string, otherRun string) bool {
+	isNumber, isOtherNumber := isDigit(run[0]), isDigit(otherRun[0])
+	if isNumber != isOtherNumber {
+		// numbers go before text
+		return isNumber
+	} else if isNumber == false {
+		return run < otherRun
+	}
+
+	// numbers of any length, 007 and 7 are the same number and are told apart as text
+	number, otherNumber := strings.TrimLeft(run, "0"), strings.TrimLeft(otherRun, "0")
+	if len(number) != len(otherNumber) {
+		return len(number) < len(otherNumber)
+	} else if number != otherNumber {
+		return number < otherNumber
+	}
+	return run < otherRun
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+// readInputOrder reads the input positions of the docIds of every task when records of a group were appended to an
+// earlier task, it is nil when the tasks are in the input doc order or were split without the input positions
+func readInputOrder(toolWorkDir string) (map[string]taskInputOrder, error) {
+	taskNames, taskNamesError := taskDirNames(toolWorkDir)
+	if taskNamesError != nil {
+		return nil, taskNamesError
+	}
+
+	taskSources := make(map[string][]misc.LineSource)
+	inputOrdered := true
+	lastIndex := 0
+	for _, taskName := range taskNames {
+		lineSources, readError := readLineSources(path.Join(toolWorkDir, taskName, misc.SOURCES_FILE_NAME))
+		if os.IsNotExist(readError) {
+			return nil, nil
+		} else if readError != nil {
+			return nil, readError
+		}
+
+		for _, lineSource := range lineSources {
+			if lineSource.Index == 0 {
+				return nil, nil
+			} else if lineSource.Index < lastIndex {
+				inputOrdered = false
+			}
+			lastIndex = lineSource.Index
+		}
+		taskSources[taskName] = lineSources
+	}
+
+	if inputOrdered {
+		return nil, nil
+	}
+
+	inputOrder := make(map[string]taskInputOrder)
+	for _, taskName := range taskNames {
+		lineSources := taskSources[taskName]
+		taskOrder := taskInputOrder{docIndexes: make(map[string]int)}
+		if len(lineSources) > 0 {
+			taskOrder.firstIndex = lineSources[0].Index
+		}
+
+		// the lines of input.json and of its sources file match one to one
+		inputFilePath := path.Join(toolWorkDir, taskName, "input.json")
+		lineNumber := 0
+		readError := readLines(inputFilePath, func(_ int, line []byte) error {
+			if lineNumber >= len(lineSources) {
+				return errors.New(fmt.Sprintf("%s has more lines than %s", inputFilePath, misc.SOURCES_FILE_NAME))
+			}
+
+			// input lines without a docId do not tell the order of any record
+			docId, docIdError := lineDocId(line)
+			if _, isKnown := taskOrder.docIndexes[docId]; docIdError == nil && isKnown == false {
+				taskOrder.docIndexes[docId] = lineSources[lineNumber].Index
+			}
+			lineNumber = lineNumber + 1
+			return nil
+		})
+		if readError != nil {
+			return nil, readError
+		}
+		inputOrder[taskName] = taskOrder
+	}
+
+	return inputOrder, nil
+}
+
+func readLineSources(sourcesFilePath string) ([]misc.LineSource, error) {
+	lineSources := make([]misc.LineSource, 0)
+	readError := readLines(sourcesFilePath, func(_ int, line []byte) error {
+		lineSource := misc.LineSource{}
+		unmarshalError := json.Unmarshal(line, &lineSource)
+		if unmarshalError != nil {
+			return errors.New(fmt.Sprintf("%s: %s", sourcesFilePath, unmarshalError.Error()))
+		}
+		lineSources = append(lineSources, lineSource)
+		return nil
+	})
+	return lineSources, readError
+}
+
+// readLines calls handleLine with every line of the file that is not empty and its line number
+func readLines(filePath string, handleLine func(lineNumber int, line []byte) error) error {
+	file, openError := os.Open(filePath)
+	if openError != nil {
+		return openError
+	}
+	defer file.Close()
+
+	// lines are read whole, documents can be larger than any scanner buffer
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readError := reader.ReadBytes('\n')
+		if readError != nil && readError != io.EOF {
+			return errors.New(fmt.Sprintf("%s: %s", filePath, readError.Error()))
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			handleError := handleLine(lineNumber, line)
+			if handleError != nil {
+				return handleError
+			}
+		}
+
+		if readError == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	registry      = make(map[string]Tool)
+	registryMutex sync.RWMutex
+)
+
+func RegisterTool(tool Tool) error {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	// tool names are used for folders and container names, so they have to be unique
+	if len(tool.Name()) == 0 {
+		return errors.New("Tool name cannot be empty")
+	}
+
+	if _, exists := registry[tool.Name()]; exists {
+		return errors.New(fmt.Sprintf("Tool %s is already registered", tool.Name()))
+	}
+
+	registry[tool.Name()] = tool
+	return nil
+}
+
+// replaceTool swaps a registered tool for a reconfigured copy of it
+func replaceTool(tool Tool) error {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, exists := registry[tool.Name()]; exists == false {
+		return errors.New(fmt.Sprintf("Unknown tool %s", tool.Name()))
+	}
+
+	registry[tool.Name()] = tool
+	return nil
+}
+
+func GetTool(toolName string) (Tool, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	tool, exists := registry[toolName]
+	if exists == false {
+		return nil, errors.New(fmt.Sprintf("Unknown tool %s", toolName))
+	}
+
+	return tool, nil
+}
+
+func ToolNames() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	toolNames := make([]string, 0, len(registry))
+	for toolName := range registry {
+		toolNames = append(toolNames, toolName)
+	}
+	sort.Strings(toolNames)
+
+	return toolNames
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"itextmine/misc"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how often a stage failing with a transient error is retried
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// StageRetries overrides MaxRetries per stage. The align key applies to every align stage.
+	StageRetries map[string]int
+}
+
+// messages of the errors caused by the container runtime rather than by the tool
+var transientErrorMessages = []string{
+	"is already in use",
+	"Cannot connect to the Docker daemon",
+	"connection refused",
+	"connection reset by peer",
+	"broken pipe",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"Client.Timeout exceeded",
+	"unexpected EOF",
+}
+
+func (policy RetryPolicy) Retries(stage string) int {
+	if retries, exists := policy.StageRetries[stage]; exists {
+		return retries
+	}
+
+	if retries, exists := policy.StageRetries["align"]; exists && strings.HasSuffix(stage, ALIGN_STAGE_SUFFIX) {
+		return retries
+	}
+
+	return policy.MaxRetries
+}
+
+func (policy RetryPolicy) Backoff(attempt int) time.Duration {
+	// exponential backoff, capped by MaxBackoff
+	backoff := policy.InitialBackoff
+	for index := 0; index < attempt; index++ {
+		backoff = backoff * 2
+		if policy.MaxBackoff > 0 && backoff >= policy.MaxBackoff {
+			return policy.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+func IsTransientError(err error) bool {
+	// tools that ran and failed fail the same way again, whatever their logs say
+	if err == nil || IsTimeoutError(err) || IsExitError(err) {
+		return false
+	}
+
+	// only the container runtime fails for a while, errors of the native aligner and of the pipeline are not retried
+	runtimeError := &misc.RuntimeError{}
+	if errors.As(err, &runtimeError) == false {
+		return false
+	}
+
+	// network timeouts talking to the daemon
+	var netError net.Error
+	if errors.As(runtimeError.Err, &netError) && netError.Timeout() {
+		return true
+	}
+
+	for _, transientErrorMessage := range transientErrorMessages {
+		if strings.Contains(runtimeError.Error(), transientErrorMessage) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func executeStageWithRetries(ctx context.Context, containerRuntime misc.ContainerRuntime, tool Tool, stage string, taskName string, workDir string, policy RetryPolicy, timeouts StageTimeouts) error {
+	retries := policy.Retries(stage)
+	timeout := timeouts.Timeout(stage)
+
+	for attempt := 0; ; attempt++ {
+		// tasks still queued when the run is interrupted or reaches its deadline are not started
+		if interrupted(ctx) {
+			return &InterruptedError{Stage: stage, TaskName: taskName}
+		} else if ctx.Err() != nil {
+			return &TimeoutError{Stage: stage, TaskName: taskName, RunDeadline: true}
+		}
+
+		stageError := executeStageWithTimeout(ctx, containerRuntime, tool, stage, taskName, workDir, timeout)
+		if stageError == nil {
+			return nil
+		}
+
+		// deterministic tool failures fail the same way when they are retried
+		if attempt >= retries || IsTransientError(stageError) == false {
+			return stageError
+		}
+
+		backoff := policy.Backoff(attempt)
+		log.Println(fmt.Sprintf("WARN: %s of %s failed with a transient error, retry %d of %d in %s: %s", stage, taskName, attempt+1, retries, backoff, stageError.Error()))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+	}
+}
+
+func executeStageWithTimeout(ctx context.Context, containerRuntime misc.ContainerRuntime, tool Tool, stage string, taskName string, workDir string, timeout time.Duration) error {
+	stageCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		stageCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	stageError := tool.ExecuteStage(stageCtx, containerRuntime, stage, taskName, workDir)
+	if stageError == nil {
+		return nil
+	}
+
+	// the stage container was stopped because the run was interrupted, or the stage or the whole run ran out of time
+	if interrupted(ctx) {
+		return &InterruptedError{Stage: stage, TaskName: taskName}
+	} else if ctx.Err() != nil {
+		return &TimeoutError{Stage: stage, TaskName: taskName, RunDeadline: true}
+	} else if stageCtx.Err() != nil {
+		return &TimeoutError{Stage: stage, TaskName: taskName, Timeout: timeout}
+	}
+
+	return stageError
+}
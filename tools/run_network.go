@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"net"
+)
+
+// runSubnet moves an IPv4 subnet to another one of the same size within its /8, picked by the run id, so that
+// concurrent runs of a tool do not ask for overlapping address pools. The subnet is kept when it cannot be moved.
+func runSubnet(runID string, subnet string) string {
+	_, ipNet, parseError := net.ParseCIDR(subnet)
+	if parseError != nil || ipNet.IP.To4() == nil {
+		return subnet
+	}
+
+	ones, _ := ipNet.Mask.Size()
+	if ones <= 8 {
+		return subnet
+	}
+
+	// the bits between the /8 and the host bits number the subnets, the run id picks at most 16 of these bits
+	hostBits := uint(32 - ones)
+	subnetBits := uint(ones - 8)
+	if subnetBits > 16 {
+		subnetBits = 16
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(runID))
+	subnetIndex := hash.Sum32() % (1 << subnetBits)
+
+	subnetMask := uint32((1<<subnetBits)-1) << hostBits
+	base := binary.BigEndian.Uint32(ipNet.IP.To4())
+	base = (base &^ subnetMask) | (subnetIndex << hostBits)
+
+	runIPNet := net.IPNet{IP: make(net.IP, 4), Mask: ipNet.Mask}
+	binary.BigEndian.PutUint32(runIPNet.IP, base)
+	return runIPNet.String()
+}
+
+// runAddress moves an address of the subnet to the same host of the subnet of the run
+func runAddress(address string, subnet string, runSubnet string) string {
+	ip := net.ParseIP(address).To4()
+	_, ipNet, parseError := net.ParseCIDR(subnet)
+	_, runIPNet, runParseError := net.ParseCIDR(runSubnet)
+	if ip == nil || parseError != nil || runParseError != nil || ipNet.Contains(ip) == false {
+		return address
+	}
+
+	host := binary.BigEndian.Uint32(ip) &^ binary.BigEndian.Uint32(net.IP(ipNet.Mask).To4())
+	runIP := make(net.IP, 4)
+	binary.BigEndian.PutUint32(runIP, binary.BigEndian.Uint32(runIPNet.IP.To4())|host)
+	return runIP.String()
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"fmt"
+	"itextmine/misc"
+	"path"
+	"strings"
+)
+
+// STAGE_PENDING is the status of a stage that the checkpoint has no record of
+const STAGE_PENDING string = "pending"
+
+// StageStatus counts the tasks by the status of a stage
+type StageStatus struct {
+	Stage    string         `json:"stage"`
+	Statuses map[string]int `json:"statuses"`
+}
+
+// RunStatus is the progress of a run, read from the task folders and the checkpoint of the tool
+type RunStatus struct {
+	Tool        string        `json:"tool"`
+	Tasks       int           `json:"tasks"`
+	Stages      []StageStatus `json:"stages"`
+	FailedTasks []string      `json:"failedTasks"`
+}
+
+func Status(workDir string, toolName string) (*RunStatus, error) {
+	tool, toolError := GetTool(toolName)
+	if toolError != nil {
+		return nil, toolError
+	}
+
+	checkpoint, checkpointError := LoadCheckpoint(CheckpointPath(workDir, tool.Name()), tool.Name())
+	if checkpointError != nil {
+		return nil, checkpointError
+	}
+
+	// the task folders, there are none before the input is split
+	taskNames := make([]string, 0)
+	if misc.TaskFoldersExist(workDir, tool.Name()) {
+		tasks, tasksError := misc.GetSubDirNames(path.Join(workDir, tool.Name()))
+		if tasksError != nil {
+			return nil, tasksError
+		}
+		taskNames = *tasks
+	}
+	misc.SortTaskNames(taskNames)
+
+	status := RunStatus{
+		Tool:        tool.Name(),
+		Tasks:       len(taskNames),
+		Stages:      make([]StageStatus, 0),
+		FailedTasks: make([]string, 0),
+	}
+
+	for _, stage := range tool.Stages() {
+		stageStatus := StageStatus{Stage: stage, Statuses: make(map[string]int)}
+		for _, taskName := range taskNames {
+			taskStatus := checkpoint.Tasks[taskName][stage]
+			if len(taskStatus) == 0 {
+				taskStatus = STAGE_PENDING
+			}
+			stageStatus.Statuses[taskStatus] = stageStatus.Statuses[taskStatus] + 1
+
+			if taskStatus != STAGE_DONE && taskStatus != STAGE_PENDING && misc.StringInSlice(taskName, status.FailedTasks) == false {
+				status.FailedTasks = append(status.FailedTasks, taskName)
+			}
+		}
+		status.Stages = append(status.Stages, stageStatus)
+	}
+	misc.SortTaskNames(status.FailedTasks)
+
+	return &status, nil
+}
+
+// Done is true once every stage of every task is done
+func (status *RunStatus) Done() bool {
+	for _, stageStatus := range status.Stages {
+		if stageStatus.Statuses[STAGE_DONE] != status.Tasks {
+			return false
+		}
+	}
+	return status.Tasks > 0
+}
+
+func (status *RunStatus) String() string {
+	lines := []string{fmt.Sprintf("%s: %d tasks", status.Tool, status.Tasks)}
+	for _, stageStatus := range status.Stages {
+		counts := make([]string, 0)
+		for _, stageState := range []string{STAGE_DONE, STAGE_FAILED, STAGE_TIMED_OUT, STAGE_PENDING} {
+			counts = append(counts, fmt.Sprintf("%d %s", stageStatus.Statuses[stageState], stageState))
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", stageStatus.Stage, strings.Join(counts, ", ")))
+	}
+
+	if len(status.FailedTasks) > 0 {
+		lines = append(lines, fmt.Sprintf("Failed tasks: %s", strings.Join(status.FailedTasks, ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
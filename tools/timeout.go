@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeoutError is returned for a stage that was stopped because it ran out of time
+type TimeoutError struct {
+	Stage    string
+	TaskName string
+	Timeout  time.Duration
+
+	// RunDeadline is set when the deadline of the whole run was reached rather than the stage timeout
+	RunDeadline bool
+}
+
+func (timeoutError *TimeoutError) Error() string {
+	if timeoutError.RunDeadline {
+		return fmt.Sprintf("%s of %s was stopped by the run deadline", timeoutError.Stage, timeoutError.TaskName)
+	}
+	return fmt.Sprintf("%s of %s timed out after %s", timeoutError.Stage, timeoutError.TaskName, timeoutError.Timeout)
+}
+
+func IsTimeoutError(err error) bool {
+	_, isTimeoutError := err.(*TimeoutError)
+	return isTimeoutError
+}
+
+// StageTimeouts limits how long a single stage of a task can run
+type StageTimeouts struct {
+	Default time.Duration
+
+	// Stages overrides Default per stage. The align key applies to every align stage.
+	Stages map[string]time.Duration
+}
+
+func (timeouts StageTimeouts) Timeout(stage string) time.Duration {
+	if timeout, exists := timeouts.Stages[stage]; exists {
+		return timeout
+	}
+
+	if timeout, exists := timeouts.Stages["align"]; exists && strings.HasSuffix(stage, ALIGN_STAGE_SUFFIX) {
+		return timeout
+	}
+
+	return timeouts.Default
+}
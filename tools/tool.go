@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"itextmine/misc"
+)
+
+// Tool is a text mining tool that can be run by the pipeline
+type Tool interface {
+	// Name of the tool. Used for the tool workdir, container names and reduced files
+	Name() string
+
+	// Images returns the docker images that have to be pulled before a run
+	Images() []string
+
+	// Stages returns the names of the stages executed, in order, for every task
+	Stages() []string
+
+	// ExecuteStage runs one stage of the tool on a single task folder
+	ExecuteStage(ctx context.Context, containerRuntime misc.ContainerRuntime, stage string, taskName string, workdir string) error
+
+	// ReduceOutputs returns the per task files that are reduced into the output dir
+	ReduceOutputs() []ReduceOutput
+
+	// CleanupPatterns returns the container names left over from a previous run, as regular expressions anchored on
+	// /name so that they do not match the containers of other runs
+	CleanupPatterns() []string
+}
+
+// SidecarTool is a tool that needs services (networks, databases) running next to its task containers
+type SidecarTool interface {
+	Tool
+
+	// Setup starts the services before any task is executed
+	Setup(ctx context.Context, containerRuntime misc.ContainerRuntime) error
+
+	// Teardown removes the services once all the tasks are done
+	Teardown(ctx context.Context, containerRuntime misc.ContainerRuntime) error
+}
+
+// alignerTool is a tool whose align steps can be switched to another aligner for a run
+type alignerTool interface {
+	withAligner(aligner string) Tool
+}
+
+// runScopedTool is a tool whose containers and networks are named and labeled by run, so that concurrent runs on a
+// host do not collide and a run only cleans up after itself
+type runScopedTool interface {
+	withRunID(runID string, moveSubnet bool) Tool
+	runLabels() map[string]string
+}
+
+// ReduceOutput describes a per task file that is concatenated into <Name>.<collection>.<Kind>.json
+type ReduceOutput struct {
+	Name     string `yaml:"name" json:"name"`
+	Kind     string `yaml:"kind" json:"kind"`
+	TaskFile string `yaml:"taskFile" json:"taskFile"`
+}
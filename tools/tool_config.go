@@ -0,0 +1,244 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ToolConfig overrides the images, container names and network settings of a manifest tool, e.g. from the pipeline config file.
+// Sidecars and stages are keyed by their name in the manifest, mounts by their source in the task folder.
+type ToolConfig struct {
+	Tag      string                   `yaml:"tag,omitempty" json:"tag,omitempty"`
+	Network  *NetworkManifest         `yaml:"network,omitempty" json:"network,omitempty"`
+	Sidecars map[string]SidecarConfig `yaml:"sidecars,omitempty" json:"sidecars,omitempty"`
+	Stages   map[string]StageConfig   `yaml:"stages,omitempty" json:"stages,omitempty"`
+}
+
+// SidecarConfig overrides the settings of a sidecar container
+type SidecarConfig struct {
+	Name         string `yaml:"name,omitempty" json:"name,omitempty"`
+	Image        string `yaml:"image,omitempty" json:"image,omitempty"`
+	IPAddress    string `yaml:"ipAddress,omitempty" json:"ipAddress,omitempty"`
+	StartupDelay string `yaml:"startupDelay,omitempty" json:"startupDelay,omitempty"`
+}
+
+// StageConfig overrides the settings of a stage container and of its align step
+type StageConfig struct {
+	Image           string            `yaml:"image,omitempty" json:"image,omitempty"`
+	ContainerPrefix string            `yaml:"containerPrefix,omitempty" json:"containerPrefix,omitempty"`
+	Mounts          map[string]string `yaml:"mounts,omitempty" json:"mounts,omitempty"`
+	AlignImage      string            `yaml:"alignImage,omitempty" json:"alignImage,omitempty"`
+	Aligner         string            `yaml:"aligner,omitempty" json:"aligner,omitempty"`
+}
+
+// ConfigureTool replaces a registered manifest tool with a copy that has the overrides of the config
+func ConfigureTool(toolName string, toolConfig ToolConfig) error {
+	tool, toolError := GetTool(toolName)
+	if toolError != nil {
+		return toolError
+	}
+
+	configuredTool, isManifestTool := tool.(*manifestTool)
+	if isManifestTool == false {
+		return errors.New(fmt.Sprintf("Tool %s is not declared by a manifest and cannot be configured", toolName))
+	}
+
+	manifest, applyError := toolConfig.apply(configuredTool.manifest)
+	if applyError != nil {
+		return applyError
+	}
+
+	validateError := manifest.Validate()
+	if validateError != nil {
+		return validateError
+	}
+
+	return replaceTool(NewManifestTool(manifest))
+}
+
+// GetToolConfig is the configuration of a registered manifest tool, in the shape of the config file
+func GetToolConfig(toolName string) (*ToolConfig, error) {
+	tool, toolError := GetTool(toolName)
+	if toolError != nil {
+		return nil, toolError
+	}
+
+	configuredTool, isManifestTool := tool.(*manifestTool)
+	if isManifestTool == false {
+		return nil, errors.New(fmt.Sprintf("Tool %s is not declared by a manifest and has no configuration", toolName))
+	}
+
+	toolConfig := ToolConfig{
+		Sidecars: make(map[string]SidecarConfig),
+		Stages:   make(map[string]StageConfig),
+	}
+
+	if configuredTool.manifest.Network != nil {
+		network := *configuredTool.manifest.Network
+		toolConfig.Network = &network
+	}
+
+	for _, sidecar := range configuredTool.manifest.Sidecars {
+		toolConfig.Sidecars[sidecar.Name] = SidecarConfig{
+			Image:        sidecar.Image,
+			IPAddress:    sidecar.IPAddress,
+			StartupDelay: sidecar.StartupDelay,
+		}
+	}
+
+	for _, stage := range configuredTool.manifest.Stages {
+		stageConfig := StageConfig{
+			Image:           stage.Image,
+			ContainerPrefix: stage.containerPrefix(),
+			Mounts:          make(map[string]string),
+		}
+		for _, mount := range stage.Mounts {
+			stageConfig.Mounts[mount.Source] = mount.Target
+		}
+		if stage.Align != nil {
+			stageConfig.AlignImage = stage.alignImage()
+			stageConfig.Aligner = stage.aligner()
+		}
+		toolConfig.Stages[stage.Name] = stageConfig
+	}
+
+	return &toolConfig, nil
+}
+
+// apply returns a copy of the manifest with the overrides of the config
+func (toolConfig ToolConfig) apply(manifest ToolManifest) (ToolManifest, error) {
+	// the network
+	if toolConfig.Network != nil {
+		if manifest.Network == nil {
+			return manifest, errors.New(fmt.Sprintf("Tool %s has no network to configure", manifest.Name))
+		}
+
+		network := *manifest.Network
+		if len(toolConfig.Network.Name) > 0 {
+			network.Name = toolConfig.Network.Name
+		}
+		if len(toolConfig.Network.Subnet) > 0 {
+			network.Subnet = toolConfig.Network.Subnet
+		}
+		manifest.Network = &network
+	}
+
+	// the sidecars
+	for sidecarName := range toolConfig.Sidecars {
+		if manifest.hasSidecar(sidecarName) == false {
+			return manifest, errors.New(fmt.Sprintf("Tool %s has no sidecar %s", manifest.Name, sidecarName))
+		}
+	}
+
+	sidecars := make([]SidecarManifest, 0, len(manifest.Sidecars))
+	for _, sidecar := range manifest.Sidecars {
+		sidecarConfig := toolConfig.Sidecars[sidecar.Name]
+		if len(sidecarConfig.Name) > 0 {
+			sidecar.Name = sidecarConfig.Name
+		}
+		if len(sidecarConfig.Image) > 0 {
+			sidecar.Image = sidecarConfig.Image
+		}
+		if len(sidecarConfig.IPAddress) > 0 {
+			sidecar.IPAddress = sidecarConfig.IPAddress
+		}
+		if len(sidecarConfig.StartupDelay) > 0 {
+			sidecar.StartupDelay = sidecarConfig.StartupDelay
+		}
+		sidecars = append(sidecars, sidecar)
+	}
+	manifest.Sidecars = sidecars
+
+	// the stages
+	for stageName := range toolConfig.Stages {
+		if manifest.hasStage(stageName) == false {
+			return manifest, errors.New(fmt.Sprintf("Tool %s has no stage %s", manifest.Name, stageName))
+		}
+	}
+
+	stages := make([]StageManifest, 0, len(manifest.Stages))
+	for _, stage := range manifest.Stages {
+		stageConfig := toolConfig.Stages[stage.Name]
+		if len(stageConfig.Image) > 0 {
+			stage.Image = stageConfig.Image
+		}
+		if len(stageConfig.ContainerPrefix) > 0 {
+			stage.ContainerPrefix = stageConfig.ContainerPrefix
+		}
+
+		// the tag is the version of the tool, the shared align image and the sidecars are versioned on their own
+		stage.Image = imageWithTag(stage.Image, toolConfig.Tag)
+
+		// the mount targets
+		mounts := make([]MountManifest, 0, len(stage.Mounts))
+		for _, mount := range stage.Mounts {
+			if target, exists := stageConfig.Mounts[mount.Source]; exists {
+				mount.Target = target
+			}
+			mounts = append(mounts, mount)
+		}
+		for source := range stageConfig.Mounts {
+			if stage.hasMount(source) == false {
+				return manifest, errors.New(fmt.Sprintf("Stage %s of tool %s has no mount of %s", stage.Name, manifest.Name, source))
+			}
+		}
+		stage.Mounts = mounts
+
+		// the align step
+		if (len(stageConfig.AlignImage) > 0 || len(stageConfig.Aligner) > 0) && stage.Align == nil {
+			return manifest, errors.New(fmt.Sprintf("Stage %s of tool %s has no align step to configure", stage.Name, manifest.Name))
+		}
+		if stage.Align != nil {
+			align := *stage.Align
+			if len(stageConfig.AlignImage) > 0 {
+				align.Image = stageConfig.AlignImage
+			}
+			if len(stageConfig.Aligner) > 0 {
+				align.Aligner = stageConfig.Aligner
+			}
+			stage.Align = &align
+		}
+
+		stages = append(stages, stage)
+	}
+	manifest.Stages = stages
+
+	return manifest, nil
+}
+
+func (manifest ToolManifest) hasSidecar(sidecarName string) bool {
+	for _, sidecar := range manifest.Sidecars {
+		if sidecar.Name == sidecarName {
+			return true
+		}
+	}
+	return false
+}
+
+func (manifest ToolManifest) hasStage(stageName string) bool {
+	for _, stage := range manifest.Stages {
+		if stage.Name == stageName {
+			return true
+		}
+	}
+	return false
+}
+
+func (stage StageManifest) hasMount(source string) bool {
+	for _, mount := range stage.Mounts {
+		if mount.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+// imageWithTag adds the tag to images that have none, e.g. itextmine/rlimsp becomes itextmine/rlimsp:1.2
+func imageWithTag(image string, tag string) string {
+	imageName := image[strings.LastIndex(image, "/")+1:]
+	if len(tag) == 0 || strings.ContainsAny(imageName, ":@") {
+		return image
+	}
+	return image + ":" + tag
+}
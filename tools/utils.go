@@ -2,7 +2,6 @@ package tools
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"itextmine/misc"
 	"log"
@@ -11,18 +10,18 @@ import (
 	"path/filepath"
 
 	"github.com/cheggaaa/pb"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 )
 
 func ExecuteAlign(ctx context.Context,
-	dockerClient *client.Client,
+	containerRuntime misc.ContainerRuntime,
 	taskName string,
 	originalJsonPath string,
 	toolOutputJsonPath string,
 	alignedJsonPath string,
+	logPath string,
 	toolName string,
+	imageName string,
+	labels map[string]string,
 ) error {
 
 	// check original json exists
@@ -43,8 +42,11 @@ func ExecuteAlign(ctx context.Context,
 		return touchError
 	}
 
-	// create bind mounts
-	hostConfig := container.HostConfig{
+	// container spec with the bind mounts
+	containerSpec := misc.ContainerSpec{
+		Name:   alignContainerName(toolName, taskName),
+		Image:  imageName,
+		Labels: labels,
 		Binds: []string{
 			fmt.Sprintf("%s:%s", originalJsonPath, "/align_workdir/origin_file.json"),
 			fmt.Sprintf("%s:%s", toolOutputJsonPath, "/align_workdir/result_file.json"),
@@ -52,36 +54,20 @@ func ExecuteAlign(ctx context.Context,
 		},
 	}
 
-	// container config
-	containerConfig := container.Config{
-		Image: "itextmine/align",
-	}
-
 	// create the container
-	containerCreateResponse, containerCreateError := dockerClient.ContainerCreate(ctx,
-		&containerConfig,
-		&hostConfig,
-		nil,
-		fmt.Sprintf("%s-align-%s", toolName, taskName),
-	)
-
+	containerID, containerCreateError := misc.CreateContainer(ctx, containerRuntime, containerSpec)
 	if containerCreateError != nil {
 		return containerCreateError
 	}
 
-	// start this container
-	containerStartError := dockerClient.ContainerStart(ctx, containerCreateResponse.ID, types.ContainerStartOptions{})
-	if containerStartError != nil {
-		return containerStartError
-	}
+	// remove the container when we are done, also when the run was interrupted or timed out
+	defer containerRuntime.ContainerRemove(context.Background(), containerID)
 
-	// wait for container to be done running
-	_, waitErr := dockerClient.ContainerWait(ctx, containerCreateResponse.ID)
-	if waitErr != nil {
-		return waitErr
+	// run the container and keep its logs with the task
+	runError := runContainer(ctx, containerRuntime, containerID, toolName+ALIGN_STAGE_SUFFIX, taskName, logPath)
+	if runError != nil {
+		return runError
 	}
-	// remove the container when we are done
-	//defer dockerClient.ContainerRemove(ctx, containerCreateResponse.ID, types.ContainerRemoveOptions{Force: true})
 
 	// check the output
 	checkoutputErr := misc.CheckOutput(alignedJsonPath)
@@ -93,7 +79,16 @@ func ExecuteAlign(ctx context.Context,
 	return nil
 }
 
-func Reduce(workDir string, outputDir string, toolName string, collectionType string) error {
+// runName prefixes a container or network name with the run id
+func runName(runID string, name string) string {
+	return fmt.Sprintf("%s-%s", runID, name)
+}
+
+func alignContainerName(toolName string, taskName string) string {
+	return fmt.Sprintf("%s-align-%s", toolName, taskName)
+}
+
+func Reduce(workDir string, outputDir string, toolName string, collectionType string, options ReduceOptions) error {
 	// build path to final workdir
 	toolWorkDir, toolWorkDirErr := filepath.Abs(path.Join(workDir, toolName))
 	if toolWorkDirErr != nil {
@@ -117,29 +112,34 @@ func Reduce(workDir string, outputDir string, toolName string, collectionType st
 		}
 	}
 
-	if toolName == "rlimsp" {
-		// reduce rlimsp
-		rlimsPpReduceError := ReduceRlimsp(toolWorkDir, outputDir, collectionType)
-		if rlimsPpReduceError != nil {
-			return rlimsPpReduceError
-		}
+	// look up the tool in the registry
+	tool, toolError := GetTool(toolName)
+	if toolError != nil {
+		return toolError
+	}
 
-		// reduce efip
-		efipReduceError := ReduceEfip(toolWorkDir, outputDir, collectionType)
-		if efipReduceError != nil {
-			return efipReduceError
+	// records of a group appended to an earlier task are put back in the input doc order
+	var inputOrder map[string]taskInputOrder
+	if options.SortByDocId == false {
+		var inputOrderError error
+		inputOrder, inputOrderError = readInputOrder(toolWorkDir)
+		if inputOrderError != nil {
+			return inputOrderError
 		}
+	}
 
-	} else if toolName == "mirtex" {
-		// reduce efip
-		mirtexReduceError := ReduceMirtex(toolWorkDir, outputDir, collectionType)
-		if mirtexReduceError != nil {
-			return mirtexReduceError
+	// reduce every task file of the tool, the errors of one output do not keep the others from being written
+	reduceErrors := make([]error, 0)
+	for _, reduceOutput := range tool.ReduceOutputs() {
+		reduceError := reduceTaskFile(toolWorkDir, outputDir, collectionType, reduceOutput, inputOrder, options)
+		if reduceError != nil {
+			reduceErrors = append(reduceErrors, reduceError)
 		}
-	} else {
-		return errors.New(fmt.Sprintf("Unknown tool %s", toolName))
 	}
 
+	if len(reduceErrors) > 0 {
+		return &ReduceError{Outputs: len(tool.ReduceOutputs()), Errors: reduceErrors}
+	}
 	return nil
 }
 